@@ -0,0 +1,192 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package bench
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"runtime"
+	"time"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+// Options of benchmark
+type Options struct {
+	Patterns   int           `json:"patterns"`    // number of patterns sampled from documents
+	PatternLen int           `json:"pattern_len"` // length of patterns
+	Extracts   int           `json:"extracts"`    // number of extractions from random positions
+	Workers    int           `json:"workers"`     // number of goroutines restore documents
+	Seed       int64         `json:"seed"`        // seed of sampling patterns and positions
+	MinTime    time.Duration `json:"min_time_ns"` // each query runs repeatedly for at least MinTime
+}
+
+// DefaultOptions samples 1000 patterns of 8 bytes, runs each query for at least 1 second
+var DefaultOptions = Options{Patterns: 1000, PatternLen: 8, Extracts: 1000, Workers: 1, Seed: 1, MinTime: time.Second}
+
+// Op latency and throughput of query
+type Op struct {
+	Name      string  `json:"name"`
+	N         int     `json:"n"` // number of operations measured
+	NsPerOp   float64 `json:"ns_per_op"`
+	OpsPerSec float64 `json:"ops_per_sec"`
+	MBPerSec  float64 `json:"mb_per_sec"` // bytes of patterns searched, or bytes extracted and restored
+}
+
+// Result of corpus
+type Result struct {
+	Corpus        string  `json:"corpus"`
+	Len           uint    `json:"len"`
+	Documents     uint    `json:"documents"`
+	BuildNs       int64   `json:"build_ns"`
+	BuildMBPerSec float64 `json:"build_mb_per_sec"`
+	Size          int     `json:"size"`
+	BitsPerSymbol float64 `json:"bits_per_symbol"`
+	Ops           []Op    `json:"ops"`
+}
+
+// Report results of corpora and environment, for regression tracking
+type Report struct {
+	GoVersion string   `json:"go_version"`
+	GOOS      string   `json:"goos"`
+	GOARCH    string   `json:"goarch"`
+	CPUs      int      `json:"cpus"`
+	Options   Options  `json:"options"`
+	Results   []Result `json:"results"`
+}
+
+// NewReport returns report of the running environment
+func NewReport(opts Options) *Report {
+	return &Report{
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		CPUs:      runtime.NumCPU(),
+		Options:   opts,
+	}
+}
+
+// Run builds index of corpus, and measures Count, Search, Extract and Restore
+func Run(c Corpus, opts Options) Result {
+	text := append([]byte{}, c.Text...)
+
+	start := time.Now()
+	fmi := ctor.New(text)
+	build := time.Since(start)
+
+	hdr, body := fmi.Size()
+	ret := Result{
+		Corpus:        c.Name,
+		Len:           fmi.Len(),
+		Documents:     fmi.Documents(),
+		BuildNs:       build.Nanoseconds(),
+		BuildMBPerSec: mbPerSec(len(c.Text), build),
+		Size:          hdr + body,
+		BitsPerSymbol: fmi.Inspect().BitsPerSymbol(),
+	}
+
+	r := rand.New(rand.NewSource(opts.Seed))
+	pats := patterns(r, c.Text, opts.Patterns, opts.PatternLen)
+	rows := make([]uint, opts.Extracts)
+	for i := range rows {
+		rows[i] = uint(r.Int63n(int64(fmi.Len())))
+	}
+
+	ret.Ops = []Op{
+		measure("count", opts.MinTime, func() (int, int) {
+			n := 0
+			for _, p := range pats {
+				fmi.Count(p)
+				n += len(p)
+			}
+			return len(pats), n
+		}),
+		measure("search", opts.MinTime, func() (int, int) {
+			n := 0
+			for _, p := range pats {
+				fmi.Occurrences(p)
+				n += len(p)
+			}
+			return len(pats), n
+		}),
+		measure("extract", opts.MinTime, func() (int, int) {
+			n := 0
+			for _, p := range rows {
+				// the rest of document
+				b, _ := fmi.ExtractRange(p, fmi.Len())
+				n += len(b)
+			}
+			return len(rows), n
+		}),
+		measure("restore", opts.MinTime, func() (int, int) {
+			fmi.RestoreTo(context.Background(), ioutil.Discard, hfmi.RestoreOptions{One: []byte{'\n'}, Workers: opts.Workers})
+			return 1, len(c.Text)
+		}),
+	}
+
+	return ret
+}
+
+// patterns returns n patterns of length sz sampled from documents of text
+func patterns(r *rand.Rand, text []byte, n, sz int) []string {
+	pats := make([]string, 0, n)
+	if sz <= 0 || sz > len(text) {
+		return pats
+	}
+	for tries := 0; len(pats) < n && tries < 100*n; tries++ {
+		s := r.Intn(len(text) - sz + 1)
+		if p := text[s : s+sz]; bytes.IndexByte(p, 1) < 0 {
+			pats = append(pats, string(p))
+		}
+	}
+	return pats
+}
+
+// measure runs f, which performs ops queries of bytes in total, repeatedly for at least min
+func measure(name string, min time.Duration, f func() (ops int, bytes int)) Op {
+	op, n := Op{Name: name}, 0
+	start := time.Now()
+	for {
+		ops, b := f()
+		if ops == 0 {
+			return op
+		}
+		op.N, n = op.N+ops, n+b
+		if time.Since(start) >= min {
+			break
+		}
+	}
+
+	d := time.Since(start)
+	op.NsPerOp = float64(d.Nanoseconds()) / float64(op.N)
+	op.OpsPerSec = float64(op.N) / d.Seconds()
+	op.MBPerSec = mbPerSec(n, d)
+	return op
+}
+
+func mbPerSec(n int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / 1e6 / d.Seconds()
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package bench
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+func TestRun(t *testing.T) {
+	c, _ := Generate("english", 20000, 1)
+	opts := DefaultOptions
+	opts.MinTime = 0
+
+	r := Run(c, opts)
+	if r.Corpus != "english" || r.Len != uint(len(c.Text))+1 || r.Documents == 0 || r.Size == 0 || r.BitsPerSymbol <= 0 || r.BitsPerSymbol > 8 {
+		t.Errorf("Run() = %+v", r)
+	}
+
+	names := []string{"count", "search", "extract", "restore"}
+	if len(r.Ops) != len(names) {
+		t.Fatalf("Run() = %v ops, want %v", len(r.Ops), len(names))
+	}
+	for i, op := range r.Ops {
+		if op.Name != names[i] || op.N == 0 || op.NsPerOp <= 0 || op.MBPerSec <= 0 {
+			t.Errorf("Run() op = %+v", op)
+		}
+	}
+	if n := r.Ops[0].N; n != opts.Patterns {
+		t.Errorf("Run() count = %v patterns, want %v", n, opts.Patterns)
+	}
+}
+
+// BenchmarkCorpora go test -bench Corpora ./bench, 4 MB of each generated corpus
+func BenchmarkCorpora(b *testing.B) {
+	for _, name := range Names() {
+		c, _ := Generate(name, 4<<20, 1)
+		fmi := ctor.New(append([]byte{}, c.Text...))
+		pats := patterns(rand.New(rand.NewSource(1)), c.Text, 1000, 8)
+
+		b.Run(name+"/build", func(b *testing.B) {
+			b.SetBytes(int64(len(c.Text)))
+			for i := 0; i < b.N; i++ {
+				ctor.New(append([]byte{}, c.Text...))
+			}
+		})
+		b.Run(name+"/count", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fmi.Count(pats[i%len(pats)])
+			}
+		})
+		b.Run(name+"/search", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fmi.Occurrences(pats[i%len(pats)])
+			}
+		})
+		b.Run(name+"/restore", func(b *testing.B) {
+			b.SetBytes(int64(len(c.Text)))
+			for i := 0; i < b.N; i++ {
+				fmi.RestoreTo(context.Background(), ioutil.Discard, hfmi.RestoreOptions{})
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+// Package bench generates Pizza&Chili style corpora, and measures build time, compression,
+// latency and throughput of queries of the index.
+package bench
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrCorpus unknown corpus name
+var ErrCorpus = errors.New("bench: unknown corpus")
+
+// Corpus generated or loaded text, documents are separated by byte 1
+type Corpus struct {
+	Name string
+	Text []byte
+}
+
+// generators of corpora, named after Pizza&Chili corpora
+var generators = map[string]func(r *rand.Rand) func() []byte{
+	"dna":     dna,
+	"english": english,
+	"xml":     xml,
+	"sources": sources,
+}
+
+// Names returns names of generated corpora in ascending order
+func Names() []string {
+	names := make([]string, 0, len(generators))
+	for n := range generators {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generate returns corpus of at least n bytes, lines are documents.
+// The same name, n and seed always generate the same text.
+func Generate(name string, n int, seed int64) (Corpus, error) {
+	gen, ok := generators[name]
+	if !ok {
+		return Corpus{}, fmt.Errorf("%w: %s", ErrCorpus, name)
+	}
+
+	line := gen(rand.New(rand.NewSource(seed)))
+	text := make([]byte, 0, n+256)
+	for len(text) < n {
+		if len(text) > 0 {
+			text = append(text, 1)
+		}
+		text = append(text, line()...)
+	}
+	return Corpus{name, text}, nil
+}
+
+// Load reads corpus from file named after the base name of file, lines are documents,
+// bytes 0 and 1 and empty lines are dropped
+func Load(file string) (Corpus, error) {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return Corpus{}, err
+	}
+
+	// note: bytes, not runes, corpora may be Latin-1 or binary
+	text := make([]byte, 0, len(d))
+	for _, l := range bytes.Split(d, []byte{'\n'}) {
+		n := len(text)
+		if n > 0 {
+			text = append(text, 1)
+		}
+		beg := len(text)
+		for _, c := range l {
+			if c > 1 {
+				text = append(text, c)
+			}
+		}
+		if len(text) == beg {
+			// empty line, drop its separator
+			text = text[:n]
+		}
+	}
+	if len(text) == 0 {
+		return Corpus{}, fmt.Errorf("%s: empty corpus", file)
+	}
+
+	return Corpus{strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)), text}, nil
+}
+
+// dna lines of 60 bases, a third are mutated copies of earlier lines, as genomes are repetitive
+func dna(r *rand.Rand) func() []byte {
+	const bases = "ACGT"
+	var seen [][]byte
+	return func() []byte {
+		line := make([]byte, 60)
+		if len(seen) > 0 && r.Intn(3) == 0 {
+			copy(line, seen[r.Intn(len(seen))])
+			for i := range line {
+				if r.Intn(100) == 0 {
+					line[i] = bases[r.Intn(4)]
+				}
+			}
+		} else {
+			for i := range line {
+				line[i] = bases[r.Intn(4)]
+			}
+			if r.Intn(50) == 0 {
+				// runs of unknown bases
+				s := r.Intn(50)
+				copy(line[s:], bytes.Repeat([]byte{'N'}, 10))
+			}
+		}
+		if len(seen) < 4096 {
+			seen = append(seen, line)
+		}
+		return line
+	}
+}
+
+// vocabulary returns n words of syllables, and Zipf distributed index of words
+func vocabulary(r *rand.Rand, n int) ([]string, *rand.Zipf) {
+	syllables := []string{"a", "e", "i", "o", "u", "ba", "ca", "de", "fi", "go", "he", "in", "jo", "ka", "le", "mi",
+		"no", "on", "pe", "qu", "re", "si", "th", "to", "un", "ve", "wa", "er", "ing", "ed", "es", "ly", "st", "tion"}
+	seen := make(map[string]bool, n)
+	words := make([]string, 0, n)
+	for len(words) < n {
+		var w strings.Builder
+		for k := 1 + r.Intn(3); k > 0; k-- {
+			w.WriteString(syllables[r.Intn(len(syllables))])
+		}
+		if !seen[w.String()] {
+			seen[w.String()] = true
+			words = append(words, w.String())
+		}
+	}
+	return words, rand.NewZipf(r, 1.1, 1, uint64(n-1))
+}
+
+// english lines of 1 to 3 sentences of Zipf distributed words
+func english(r *rand.Rand) func() []byte {
+	words, zipf := vocabulary(r, 5000)
+	return func() []byte {
+		var line bytes.Buffer
+		for s := 1 + r.Intn(3); s > 0; s-- {
+			if line.Len() > 0 {
+				line.WriteByte(' ')
+			}
+			for i, n := 0, 4+r.Intn(16); i < n; i++ {
+				w := words[zipf.Uint64()]
+				if i == 0 {
+					w = title(w)
+				} else {
+					line.WriteByte(' ')
+				}
+				line.WriteString(w)
+				if i > 0 && i < n-1 && r.Intn(12) == 0 {
+					line.WriteByte(',')
+				}
+			}
+			line.WriteByte('.')
+		}
+		return line.Bytes()
+	}
+}
+
+// xml lines of bibliography records
+func xml(r *rand.Rand) func() []byte {
+	words, zipf := vocabulary(r, 2000)
+	tags := []string{"article", "inproceedings", "book", "phdthesis"}
+	id := 0
+	return func() []byte {
+		id++
+		tag := tags[r.Intn(len(tags))]
+		name := make([]string, 2+r.Intn(8))
+		for i := range name {
+			name[i] = words[zipf.Uint64()]
+		}
+		return []byte(fmt.Sprintf(`<%s key="rec/%d" mdate="20%02d-%02d-%02d"><author>%s %s</author><title>%s</title><year>%d</year><pages>%d-%d</pages></%s>`,
+			tag, id, r.Intn(21), 1+r.Intn(12), 1+r.Intn(28), title(words[zipf.Uint64()]), title(words[r.Intn(len(words))]),
+			strings.Join(name, " "), 1970+r.Intn(51), id%500, id%500+r.Intn(20), tag))
+	}
+}
+
+// sources lines of Go like source code
+func sources(r *rand.Rand) func() []byte {
+	words, zipf := vocabulary(r, 500)
+	ident := func() string {
+		w := words[zipf.Uint64()]
+		if r.Intn(3) == 0 {
+			w += title(words[zipf.Uint64()])
+		}
+		return w
+	}
+	types := []string{"int", "string", "[]byte", "error", "bool", "uint", "*Node", "map[string]int"}
+	templates := []func() string{
+		func() string {
+			return fmt.Sprintf("func %s(%s %s) %s {", ident(), ident(), types[r.Intn(len(types))], types[r.Intn(len(types))])
+		},
+		func() string { return "\tif err != nil {" },
+		func() string { return "\t\treturn nil, err" },
+		func() string { return "\t}" },
+		func() string { return "}" },
+		func() string { return fmt.Sprintf("\t%s := %s.%s(%s)", ident(), ident(), title(ident()), ident()) },
+		func() string { return fmt.Sprintf("\tfor i := 0; i < len(%s); i++ {", ident()) },
+		func() string { return fmt.Sprintf("\t\t%s[i] += %d", ident(), r.Intn(64)) },
+		func() string {
+			return fmt.Sprintf("// %s %s %s %s", ident(), words[zipf.Uint64()], words[zipf.Uint64()], words[zipf.Uint64()])
+		},
+		func() string { return fmt.Sprintf("\treturn %s", ident()) },
+	}
+	return func() []byte {
+		return []byte(templates[r.Intn(len(templates))]())
+	}
+}
+
+// title returns w with the first letter in upper case
+func title(w string) string {
+	return strings.ToUpper(w[:1]) + w[1:]
+}
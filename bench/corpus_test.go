@@ -0,0 +1,75 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package bench
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			c, err := Generate(name, 10000, 7)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if c.Name != name || len(c.Text) < 10000 || len(c.Text) > 10000+512 {
+				t.Errorf("Generate() = %s of %d bytes", c.Name, len(c.Text))
+			}
+			if bytes.IndexByte(c.Text, 0) >= 0 || c.Text[0] == 1 || c.Text[len(c.Text)-1] == 1 || bytes.Contains(c.Text, []byte{1, 1}) {
+				t.Errorf("Generate() = %q, invalid documents", c.Text)
+			}
+
+			again, _ := Generate(name, 10000, 7)
+			other, _ := Generate(name, 10000, 8)
+			if !bytes.Equal(again.Text, c.Text) || bytes.Equal(other.Text, c.Text) {
+				t.Errorf("Generate() is not determined by seed")
+			}
+		})
+	}
+
+	if _, err := Generate("wiki", 100, 1); !errors.Is(err, ErrCorpus) {
+		t.Errorf("Generate() error = %v, want %v", err, ErrCorpus)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lines.txt")
+	if err := ioutil.WriteFile(file, []byte("one\n\ntw\x01o\n\x00\nthree\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Load(file)
+	if err != nil || c.Name != "lines" || string(c.Text) != "one\x01two\x01three" {
+		t.Errorf("Load() = %s %q, %v", c.Name, c.Text, err)
+	}
+	// Latin-1 bytes aren't UTF-8, they are kept as is
+	file = filepath.Join(t.TempDir(), "latin1.txt")
+	if err := ioutil.WriteFile(file, []byte("a\xe9b\x01c\n\xff\xfe"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = Load(file); err != nil || string(c.Text) != "a\xe9bc\x01\xff\xfe" {
+		t.Errorf("Load() = %q, %v", c.Text, err)
+	}
+}
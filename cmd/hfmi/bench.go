@@ -0,0 +1,103 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strings"
+
+	"github.com/rleiwang/hfmi/bench"
+)
+
+func benchmark(args []string, stdin io.Reader, stdout io.Writer) error {
+	f := newFlags("bench", false)
+	corpora := f.String("corpus", strings.Join(bench.Names(), ","), "generated corpora, comma separated, empty for none")
+	size := f.Int("size", 16<<20, "bytes of each generated corpus")
+	out := f.String("o", "", "write JSON report to file")
+	opts := bench.DefaultOptions
+	f.Int64Var(&opts.Seed, "seed", opts.Seed, "seed of generated corpora and sampled patterns")
+	f.IntVar(&opts.Patterns, "patterns", opts.Patterns, "number of patterns sampled from documents")
+	f.IntVar(&opts.PatternLen, "len", opts.PatternLen, "length of patterns")
+	f.IntVar(&opts.Extracts, "extracts", opts.Extracts, "number of extractions from random positions")
+	f.IntVar(&opts.Workers, "workers", runtime.NumCPU(), "number of goroutines restore documents")
+	f.DurationVar(&opts.MinTime, "time", opts.MinTime, "min time of each query")
+	if err := f.parse(args, 0); err != nil {
+		return err
+	}
+
+	// generated corpora, then corpora loaded from files
+	var cs []bench.Corpus
+	for _, name := range strings.Split(*corpora, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		c, err := bench.Generate(name, *size, opts.Seed)
+		if err != nil {
+			return fmt.Errorf("bench: %w", err)
+		}
+		cs = append(cs, c)
+	}
+	for _, file := range f.Args() {
+		c, err := bench.Load(file)
+		if err != nil {
+			return fmt.Errorf("bench: %w", err)
+		}
+		cs = append(cs, c)
+	}
+	if len(cs) == 0 {
+		return fmt.Errorf("bench: no corpus")
+	}
+
+	report := bench.NewReport(opts)
+	for _, c := range cs {
+		r := bench.Run(c, opts)
+		report.Results = append(report.Results, r)
+		if f.json {
+			continue
+		}
+
+		var text strings.Builder
+		fmt.Fprintf(&text, "%s\t%d bytes\t%d documents\tbuild %.2f MB/s\t%.3f bits per symbol\n", r.Corpus, r.Len, r.Documents, r.BuildMBPerSec, r.BitsPerSymbol)
+		for _, op := range r.Ops {
+			fmt.Fprintf(&text, "  %s\t%.0f ns/op\t%.0f ops/s\t%.2f MB/s\n", op.Name, op.NsPerOp, op.OpsPerSec, op.MBPerSec)
+		}
+		if err := f.print(stdout, nil, text.String()); err != nil {
+			return err
+		}
+	}
+
+	if *out != "" {
+		d, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(*out, append(d, '\n'), 0644); err != nil {
+			return fmt.Errorf("bench: %w", err)
+		}
+	}
+	if f.json {
+		return f.print(stdout, report, "")
+	}
+	return nil
+}
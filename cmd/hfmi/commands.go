@@ -0,0 +1,366 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+func build(args []string, stdin io.Reader, stdout io.Writer) error {
+	f := newFlags("build", false)
+	out := f.String("o", "", "output index file")
+	sep := f.String("sep", `\n`, "byte separates documents in text file")
+	ints := f.Bool("ints", false, "text file is integers separated by white space, e.g. token ids")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("build: -o output index file is required")
+	}
+	s, err := separator(*sep)
+	if err != nil {
+		return fmt.Errorf("build: %w", err)
+	}
+
+	var text []byte
+	if name := f.Arg(0); name == "-" {
+		text, err = ioutil.ReadAll(stdin)
+	} else {
+		text, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return fmt.Errorf("build: %w", err)
+	}
+
+	if *ints {
+		return buildInts(f, *out, text, stdout)
+	}
+
+	if text, err = documents(text, s); err != nil {
+		return fmt.Errorf("build: %s: %w", f.Arg(0), err)
+	}
+
+	fmi := ctor.New(text)
+	if err = ctor.WriteFile(*out, fmi); err != nil {
+		return fmt.Errorf("build: %w", err)
+	}
+
+	hdr, body := fmi.Size()
+	return f.print(stdout, struct {
+		Index     string `json:"index"`
+		Len       uint   `json:"len"`
+		Documents uint   `json:"documents"`
+		Size      int    `json:"size"`
+	}{*out, fmi.Len(), fmi.Documents(), hdr + body},
+		fmt.Sprintf("%s: %d bytes, %d documents, %d bytes compressed\n", *out, len(text), fmi.Documents(), hdr+body))
+}
+
+// documents replaces sep with separator byte 1, the index doesn't support byte 0. a trailing sep ends the last
+// document, empty documents are rejected, as the index doesn't support consecutive separators, and dropping
+// them would shift ids of the following documents
+func documents(text []byte, sep byte) ([]byte, error) {
+	if i := bytes.IndexByte(text, 0); i >= 0 {
+		return nil, fmt.Errorf("byte 0 at offset %d", i)
+	}
+	if sep != 1 {
+		if i := bytes.IndexByte(text, 1); i >= 0 {
+			return nil, fmt.Errorf("byte 1 at offset %d", i)
+		}
+	}
+
+	text = bytes.TrimSuffix(text, []byte{sep})
+	if len(text) == 0 {
+		return nil, fmt.Errorf("empty text")
+	}
+
+	ret := make([]byte, 0, len(text))
+	line := 1
+	for i, d := range bytes.Split(text, []byte{sep}) {
+		if len(d) == 0 {
+			return nil, fmt.Errorf("empty document %d at line %d", i, line)
+		}
+		if i > 0 {
+			ret = append(ret, 1)
+		}
+		ret = append(ret, d...)
+		if line += bytes.Count(d, []byte{'\n'}); sep == '\n' {
+			line++
+		}
+	}
+	return ret, nil
+}
+
+// buildInts builds index file of integer sequence
+func buildInts(f *flags, out string, text []byte, stdout io.Writer) error {
+	fields := bytes.Fields(text)
+	t := make([]uint32, len(fields))
+	for i, b := range fields {
+		a, err := strconv.ParseUint(string(b), 10, 32)
+		if err != nil {
+			return fmt.Errorf("build: %s: integer %d: %w", f.Arg(0), i, err)
+		}
+		t[i] = uint32(a)
+	}
+
+	fmi := ctor.NewInts(t)
+	if err := ctor.WriteIntsFile(out, fmi); err != nil {
+		return fmt.Errorf("build: %w", err)
+	}
+
+	return f.print(stdout, struct {
+		Index string `json:"index"`
+		Len   uint   `json:"len"`
+		Size  int    `json:"size"`
+	}{out, fmi.Len(), len(fmi.Bytes())},
+		fmt.Sprintf("%s: %d integers, %d bytes\n", out, len(t), len(fmi.Bytes())))
+}
+
+// readIndex reads index file of text, or of integer sequence if ints isn't nil
+func readIndex(file string) (fmi hfmi.FMI, ints hfmi.IntFMI, err error) {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fmi, err = ctor.Unmarshal(d); err == nil {
+		return fmi, nil, nil
+	}
+	// a bare ErrFormat is of the other kind
+	ints, ierr := ctor.UnmarshalInts(d)
+	if ierr == nil {
+		return nil, ints, nil
+	}
+	if ierr != ctor.ErrFormat {
+		err = ierr
+	}
+	return nil, nil, fmt.Errorf("%s: %w", file, err)
+}
+
+func count(args []string, stdin io.Reader, stdout io.Writer) error {
+	f := newFlags("count", true)
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	fmi, ints, err := readIndex(f.index)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range f.Args() {
+		var r countResult
+		if ints != nil {
+			if r, err = countInts(ints, p); err != nil {
+				return fmt.Errorf("count: %w", err)
+			}
+		} else {
+			r = countOf(fmi, p)
+		}
+		if err = f.print(stdout, r, fmt.Sprintf("%s\t%d\n", r.Pattern, r.Count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func search(args []string, stdin io.Reader, stdout io.Writer) error {
+	f := newFlags("search", true)
+	limit := f.Int("limit", 100, "max number of occurrences located, negative for all")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	fmi, ints, err := readIndex(f.index)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range f.Args() {
+		var r searchResult
+		if ints != nil {
+			r, err = searchInts(context.Background(), ints, p, *limit)
+		} else {
+			r, err = searchOf(context.Background(), fmi, p, *limit)
+		}
+		if err != nil {
+			return fmt.Errorf("search: %w", err)
+		}
+
+		var text strings.Builder
+		fmt.Fprintf(&text, "%s\t(%d, %d]", r.Pattern, r.Range[0], r.Range[1])
+		for _, o := range r.Offsets {
+			text.WriteString("\t" + strconv.FormatUint(uint64(o), 10))
+		}
+		text.WriteByte('\n')
+
+		if err = f.print(stdout, r, text.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extract(args []string, stdin io.Reader, stdout io.Writer) error {
+	f := newFlags("extract", true)
+	from := f.Uint("from", 0, "BWT position extraction starts from")
+	to := f.Uint("to", 0, "BWT position extraction ends at")
+	if err := f.parse(args, 0); err != nil {
+		return err
+	}
+	fmi, err := ctor.ReadFile(f.index)
+	if err != nil {
+		return err
+	}
+
+	r, err := extractOf(fmi, *from, *to)
+	if err != nil {
+		return fmt.Errorf("extract: %w", err)
+	}
+	return f.print(stdout, r, r.Text+"\n")
+}
+
+func fields(args []string, stdin io.Reader, stdout io.Writer) error {
+	f := newFlags("fields", true)
+	fc := f.Uint("fc", 1, "number of fields of each row")
+	limit := f.Int("limit", 100, "max number of rows printed, negative for all")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	fmi, err := ctor.ReadFile(f.index)
+	if err != nil {
+		return err
+	}
+
+	rows, err := fieldsOf(context.Background(), fmi, f.Arg(0), *fc, *limit)
+	if err != nil {
+		return fmt.Errorf("fields: %w", err)
+	}
+	for _, r := range rows {
+		if err = f.print(stdout, r, strings.Join(r.Fields, "\t")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func restore(args []string, stdin io.Reader, stdout io.Writer) error {
+	f := newFlags("restore", true)
+	doc := f.Int("doc", -1, "document id, negative for the whole text")
+	sep := f.String("sep", `\n`, "byte rendered for separators")
+	workers := f.Int("workers", runtime.NumCPU(), "number of goroutines restore documents concurrently")
+	if err := f.parse(args, 0); err != nil {
+		return err
+	}
+	s, err := separator(*sep)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	fmi, ints, err := readIndex(f.index)
+	if err != nil {
+		return err
+	}
+	if ints != nil {
+		return restoreInts(f, ints, *doc, stdout)
+	}
+
+	w := stdout
+	var buf bytes.Buffer
+	if f.json {
+		w = &buf
+	}
+
+	ctx := context.Background()
+	if *doc >= 0 {
+		if err = fmi.RestoreDocument(ctx, w, uint(*doc)); err == nil && !f.json {
+			_, err = io.WriteString(w, "\n")
+		}
+	} else {
+		err = fmi.RestoreTo(ctx, w, hfmi.RestoreOptions{One: []byte{s}, Zero: []byte{'\n'}, Workers: *workers})
+	}
+	if err != nil || !f.json {
+		return err
+	}
+
+	return f.print(stdout, restoreResult{*doc, buf.String()}, "")
+}
+
+// restoreInts prints integer sequence separated by spaces, it has no documents
+func restoreInts(f *flags, ints hfmi.IntFMI, doc int, stdout io.Writer) error {
+	if doc >= 0 {
+		return fmt.Errorf("restore: index of integer sequence has no documents")
+	}
+
+	t, _ := ints.Extract(0, ints.Len())
+	strs := make([]string, len(t))
+	for i, a := range t {
+		strs[i] = strconv.FormatUint(uint64(a), 10)
+	}
+	text := strings.Join(strs, " ")
+	return f.print(stdout, restoreResult{doc, text}, text+"\n")
+}
+
+func inspect(args []string, stdin io.Reader, stdout io.Writer) error {
+	f := newFlags("inspect", true)
+	if err := f.parse(args, 0); err != nil {
+		return err
+	}
+	fmi, err := ctor.ReadFile(f.index)
+	if err != nil {
+		return err
+	}
+
+	r := fmi.Inspect()
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "symbols\t%d\nblocks\t%d x %d\nheader\t%d bytes\nbody\t%d bytes\nsuper\t%d blocks, %d bytes in memory\nbits\t%.3f per symbol\n",
+		r.Len, r.Blocks, r.BlockSize, r.HeaderBytes, r.BodyBytes, r.SuperBlocks, r.SuperBytes, r.BitsPerSymbol())
+
+	type encoding struct {
+		hfmi.Encoding
+		AvgSymbols float64 `json:"avg_symbols"`
+	}
+	encs := make([]encoding, len(r.Encodings))
+	fmt.Fprintf(&text, "\nencoding\tblocks\theader\tbody\tavg symbols\n")
+	for i, e := range r.Encodings {
+		encs[i].Encoding = e
+		if e.Blocks > 0 {
+			encs[i].AvgSymbols = float64(e.Symbols) / float64(e.Blocks)
+		}
+		fmt.Fprintf(&text, "%s\t%d\t%d\t%d\t%.1f\n", e.Name, e.Blocks, e.HeaderBytes, e.BodyBytes, encs[i].AvgSymbols)
+	}
+
+	fmt.Fprintf(&text, "\nentropy\tblocks\n")
+	for i, n := range r.Entropy {
+		fmt.Fprintf(&text, "[%d, %d)\t%d\n", i, i+1, n)
+	}
+
+	return f.print(stdout, struct {
+		hfmi.Report
+		BitsPerSymbol float64    `json:"bits_per_symbol"`
+		Encodings     []encoding `json:"encodings"`
+	}{r, r.BitsPerSymbol(), encs}, text.String())
+}
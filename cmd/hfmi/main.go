@@ -0,0 +1,162 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+// Command hfmi builds and queries FM-index files.
+//
+//	hfmi build [-ints] -o text.hfmi text.txt
+//	hfmi count -i text.hfmi pattern...
+//	hfmi search -i text.hfmi pattern...
+//	hfmi extract -i text.hfmi -from p -to q
+//	hfmi fields -i text.hfmi -fc n pattern
+//	hfmi restore -i text.hfmi [-doc id]
+//
+//	hfmi inspect -i text.hfmi
+//	hfmi serve -addr :8080 [name=]text.hfmi...
+//	hfmi bench [-corpus dna,english] [-o report.json] [corpus.txt...]
+//
+// Every subcommand takes -json to print one JSON object per result. count, search and restore also read
+// index files of integer sequence built by build -ints, patterns are integers separated by commas.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command subcommand, args exclude the subcommand name
+type command struct {
+	usage string
+	run   func(args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"bench":   {"benchmark build, compression and queries of corpora", benchmark},
+	"build":   {"build text file into index file, lines are separated documents, blank lines are rejected, -ints for integers", build},
+	"count":   {"count occurrences of patterns", count},
+	"search":  {"print BWT ranges and text offsets of patterns", search},
+	"extract": {"extract text between BWT positions", extract},
+	"fields":  {"print fields of rows where pattern occurs", fields},
+	"inspect": {"print compression report of blocks", inspect},
+	"restore": {"restore the original text or a document", restore},
+	"serve":   {"serve queries of index files over HTTP", serve},
+}
+
+// errUsage bad arguments, usage is already printed
+var errUsage = errors.New("usage")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if err != errUsage {
+			fmt.Fprintln(os.Stderr, "hfmi:", err)
+		}
+		os.Exit(2)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return usage()
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return usage()
+	}
+	return cmd.run(args[1:], stdin, stdout)
+}
+
+func usage() error {
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage: hfmi <command> [flags] [args]")
+	for _, n := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", n, commands[n].usage)
+	}
+	return errUsage
+}
+
+// flags flag set of subcommand, with common flags
+type flags struct {
+	*flag.FlagSet
+	index string
+	json  bool
+}
+
+func newFlags(name string, index bool) *flags {
+	f := &flags{FlagSet: flag.NewFlagSet(name, flag.ContinueOnError)}
+	if index {
+		f.StringVar(&f.index, "i", "", "index file")
+	}
+	f.BoolVar(&f.json, "json", false, "print JSON")
+	return f
+}
+
+// parse parses args, at least n positional args are required
+func (f *flags) parse(args []string, n int) error {
+	if err := f.Parse(args); err != nil {
+		return errUsage
+	}
+	if f.Lookup("i") != nil && f.index == "" {
+		return fmt.Errorf("%s: -i index file is required", f.Name())
+	}
+	if f.NArg() < n {
+		f.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// print writes v as JSON, or text
+func (f *flags) print(w io.Writer, v interface{}, text string) error {
+	if f.json {
+		return json.NewEncoder(w).Encode(v)
+	}
+	_, err := io.WriteString(w, text)
+	return err
+}
+
+// separator parses byte of escaped string, e.g. \n, \t
+func separator(s string) (byte, error) {
+	switch s {
+	case `\n`:
+		return '\n', nil
+	case `\t`:
+		return '\t', nil
+	case `\r`:
+		return '\r', nil
+	}
+	if len(s) != 1 {
+		return 0, fmt.Errorf("separator must be one byte: %q", s)
+	}
+	return s[0], nil
+}
+
+// render replaces separators with r, for text output
+func render(b []byte, r string) string {
+	return strings.ReplaceAll(string(b), "\x01", r)
+}
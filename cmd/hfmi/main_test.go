@@ -0,0 +1,203 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rleiwang/hfmi/bench"
+)
+
+const text = "the cat sat\nthe dog ran\na cat ran\n"
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	idx := filepath.Join(dir, "t.hfmi")
+
+	var out bytes.Buffer
+	if err := run([]string{"build", "-o", idx, "-"}, strings.NewReader(text), &out); err != nil {
+		t.Fatalf("build error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"count", []string{"count", "-i", idx, "cat", "dog", "cow"}, "cat\t2\ndog\t1\ncow\t0\n"},
+		{"count json", []string{"count", "-json", "-i", idx, "ran"}, `{"pattern":"ran","count":2}` + "\n"},
+		{"search", []string{"search", "-json", "-i", idx, "cat"}, `{"pattern":"cat","range":[30,32],"count":2,"offsets":[4,26]}` + "\n"},
+		{"search limit", []string{"search", "-json", "-limit", "1", "-i", idx, "cat"}, `{"pattern":"cat","range":[30,32],"count":2,"offsets":[26]}` + "\n"},
+		{"fields", []string{"fields", "-i", idx, "dog"}, "the dog ran\n"},
+		{"restore", []string{"restore", "-i", idx}, text},
+		{"restore doc", []string{"restore", "-json", "-i", idx, "-doc", "2"}, `{"document":2,"text":"a cat ran"}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run(tt.args, nil, &out); err != nil || out.String() != tt.want {
+				t.Errorf("run() = %q, %v, want %q", out.String(), err, tt.want)
+			}
+		})
+	}
+
+	out.Reset()
+	if err := run([]string{"inspect", "-json", "-i", idx}, nil, &out); err != nil || !strings.Contains(out.String(), `"len":34,"block_size":256,"blocks":1,`) {
+		t.Errorf("inspect = %q, %v", out.String(), err)
+	}
+}
+
+func TestRunInts(t *testing.T) {
+	dir := t.TempDir()
+	idx := filepath.Join(dir, "t.hfmw")
+
+	ints := "3 1 4 1 5 9\n2 6 5 3 5\n"
+	var out bytes.Buffer
+	if err := run([]string{"build", "-ints", "-o", idx, "-"}, strings.NewReader(ints), &out); err != nil {
+		t.Fatalf("build error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"count", []string{"count", "-i", idx, "5", "1,5", "7"}, "5\t3\n1,5\t1\n7\t0\n"},
+		{"search", []string{"search", "-json", "-i", idx, "3"}, `{"pattern":"3","range":[3,5],"count":2,"offsets":[0,9]}` + "\n"},
+		{"restore", []string{"restore", "-i", idx}, "3 1 4 1 5 9 2 6 5 3 5\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run(tt.args, nil, &out); err != nil || out.String() != tt.want {
+				t.Errorf("run() = %q, %v, want %q", out.String(), err, tt.want)
+			}
+		})
+	}
+
+	for _, args := range [][]string{
+		{"count", "-i", idx, "a"},
+		{"restore", "-i", idx, "-doc", "0"},
+		{"fields", "-i", idx, "3"},
+		{"build", "-ints", "-o", idx, "-"},
+	} {
+		if err := run(args, strings.NewReader("1 -2"), ioutil.Discard); err == nil {
+			t.Errorf("run(%v) error = nil", args)
+		}
+	}
+}
+
+func TestRunError(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.txt")
+	if err := ioutil.WriteFile(bad, []byte("\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"empty document", []string{"build", "-o", filepath.Join(dir, "bad.hfmi"), bad}},
+		{"not index", []string{"count", "-i", bad, "a"}},
+		{"no index", []string{"count", "a"}},
+		{"unknown", []string{"unknown"}},
+		{"unknown corpus", []string{"bench", "-corpus", "wiki"}},
+		{"no corpus", []string{"bench", "-corpus", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := run(tt.args, nil, ioutil.Discard); err == nil {
+				t.Errorf("run(%v) error = nil", tt.args)
+			}
+		})
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		sep  byte
+		want string
+	}{
+		{"lines", "a\nb\nc", '\n', "a\x01b\x01c"},
+		{"trailing line", "a\nb\n", '\n', "a\x01b"},
+		{"tabs", "a\tb\t", '\t', "a\x01b"},
+		{"separator", "a\x01b", 1, "a\x01b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := documents([]byte(tt.text), tt.sep); err != nil || string(got) != tt.want {
+				t.Errorf("documents(%q) = %q, %v, want %q", tt.text, got, err, tt.want)
+			}
+		})
+	}
+
+	errs := []struct {
+		text string
+		sep  byte
+		err  string
+	}{
+		{"", '\n', "empty text"},
+		{"\n", '\n', "empty text"},
+		{"\na\nb", '\n', "empty document 0 at line 1"},
+		{"a\nb\n\nc\n", '\n', "empty document 2 at line 3"},
+		{"a\nb\tc\t\td", '\t', "empty document 2 at line 2"},
+		{"a\x01\x01b", 1, "empty document 1 at line 1"},
+		{"a\x00b", '\n', "byte 0 at offset 1"},
+		{"a\x01b", '\n', "byte 1 at offset 1"},
+	}
+	for _, tt := range errs {
+		if got, err := documents([]byte(tt.text), tt.sep); err == nil || err.Error() != tt.err {
+			t.Errorf("documents(%q) = %q, %v, want error %q", tt.text, got, err, tt.err)
+		}
+	}
+}
+
+func TestBench(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.json")
+
+	var out bytes.Buffer
+	if err := run([]string{"bench", "-json", "-corpus", "dna,xml", "-size", "4096", "-time", "0", "-o", file}, nil, &out); err != nil {
+		t.Fatalf("bench error = %v", err)
+	}
+
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report, printed bench.Report
+	if err = json.Unmarshal(d, &report); err != nil {
+		t.Fatalf("report = %s, %v", d, err)
+	}
+	if err = json.Unmarshal(out.Bytes(), &printed); err != nil || !reflect.DeepEqual(printed.Options, report.Options) {
+		t.Errorf("bench = %s, %v", out.String(), err)
+	}
+	if len(report.Results) != 2 || report.Results[0].Corpus != "dna" || report.Results[1].Corpus != "xml" || len(report.Results[1].Ops) != 4 {
+		t.Errorf("report = %s", d)
+	}
+}
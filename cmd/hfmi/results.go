@@ -0,0 +1,171 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rleiwang/hfmi"
+)
+
+// results of queries, shared by subcommands and server
+
+type countResult struct {
+	Pattern string `json:"pattern"`
+	Count   uint   `json:"count"`
+}
+
+type searchResult struct {
+	Pattern string `json:"pattern"`
+	Range   []uint `json:"range"`
+	Count   uint   `json:"count"`
+	Offsets []uint `json:"offsets"`
+}
+
+type extractResult struct {
+	From uint   `json:"from"`
+	To   uint   `json:"to"`
+	Text string `json:"text"`
+}
+
+type fieldsResult struct {
+	Pattern  string   `json:"pattern"`
+	Position uint     `json:"position"`
+	Fields   []string `json:"fields"`
+}
+
+type restoreResult struct {
+	Document int    `json:"document"`
+	Text     string `json:"text"`
+}
+
+func countOf(fmi hfmi.FMI, p string) countResult {
+	return countResult{p, fmi.Count(p)}
+}
+
+// searchOf returns range and text offsets of up to limit rows of p, negative limit for all,
+// only the returned rows are located, offsets are sorted. it stops when ctx is done
+func searchOf(ctx context.Context, fmi hfmi.FMI, p string, limit int) (searchResult, error) {
+	rng, ok := fmi.Search(p)
+	if !ok {
+		return searchResult{Pattern: p, Range: []uint{0, 0}, Offsets: []uint{}}, nil
+	}
+
+	offs := []uint{}
+	for i := rng[0] + 1; i <= rng[1] && (limit < 0 || len(offs) < limit); i++ {
+		if err := ctx.Err(); err != nil {
+			return searchResult{}, err
+		}
+		off, _ := fmi.Offset(i)
+		// offset of the last char in pattern
+		offs = append(offs, off+1-uint(len(p)))
+	}
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+	return searchResult{p, rng, rng[1] - rng[0], offs}, nil
+}
+
+// intsOf parses integers of pattern p separated by commas
+func intsOf(p string) ([]uint32, error) {
+	strs := strings.Split(p, ",")
+	pat := make([]uint32, len(strs))
+	for i, s := range strs {
+		a, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", p, err)
+		}
+		pat[i] = uint32(a)
+	}
+	return pat, nil
+}
+
+func countInts(fmi hfmi.IntFMI, p string) (countResult, error) {
+	pat, err := intsOf(p)
+	if err != nil {
+		return countResult{}, err
+	}
+	return countResult{p, fmi.Count(pat)}, nil
+}
+
+// searchInts returns range and text offsets of up to limit rows of p as searchOf does, p is integers separated
+// by commas
+func searchInts(ctx context.Context, fmi hfmi.IntFMI, p string, limit int) (searchResult, error) {
+	pat, err := intsOf(p)
+	if err != nil {
+		return searchResult{}, err
+	}
+	rng, ok := fmi.Search(pat)
+	if !ok {
+		return searchResult{Pattern: p, Range: []uint{0, 0}, Offsets: []uint{}}, nil
+	}
+
+	offs := []uint{}
+	for i := rng[0] + 1; i <= rng[1] && (limit < 0 || len(offs) < limit); i++ {
+		if err := ctx.Err(); err != nil {
+			return searchResult{}, err
+		}
+		off, _ := fmi.Offset(i)
+		offs = append(offs, off+1-uint(len(pat)))
+	}
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+	return searchResult{p, rng, rng[1] - rng[0], offs}, nil
+}
+
+func extractOf(fmi hfmi.FMI, from, to uint) (extractResult, error) {
+	if from >= fmi.Len() || to >= fmi.Len() {
+		return extractResult{}, fmt.Errorf("position out of range [0, %d)", fmi.Len())
+	}
+
+	b, _ := fmi.ExtractRange(from, to)
+	return extractResult{from, to, string(b)}, nil
+}
+
+// fieldsOf returns fields of up to limit rows where p occurs, negative limit for all, it stops when ctx is done
+func fieldsOf(ctx context.Context, fmi hfmi.FMI, p string, fc uint, limit int) ([]fieldsResult, error) {
+	if fc == 0 {
+		return nil, fmt.Errorf("number of fields must be positive")
+	}
+
+	rng, ok := fmi.Search(p)
+	if !ok {
+		return nil, nil
+	}
+
+	var ret []fieldsResult
+	for i := rng[0] + 1; i <= rng[1] && (limit < 0 || len(ret) < limit); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		row, ok := fmi.ExtractFields(1, i, fc)
+		if !ok {
+			return nil, fmt.Errorf("failed to extract fields at %d", i)
+		}
+
+		strs := make([]string, len(row))
+		for j, b := range row {
+			strs[j] = string(b)
+		}
+		ret = append(ret, fieldsResult{p, i, strs})
+	}
+	return ret, nil
+}
@@ -0,0 +1,295 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+// server serves queries of loaded indexes, at most cap(sem) queries run concurrently
+type server struct {
+	indexes map[string]hfmi.FMI
+	sem     chan struct{}
+	timeout time.Duration
+}
+
+// errBusy no query slot is released before timeout
+var errBusy = errors.New("too many concurrent queries")
+
+func serve(args []string, stdin io.Reader, stdout io.Writer) error {
+	f := newFlags("serve", false)
+	addr := f.String("addr", ":8080", "listen address")
+	timeout := f.Duration("timeout", 10*time.Second, "query timeout, including the wait for a query slot, restore streams are not bounded")
+	concurrency := f.Int("concurrency", runtime.NumCPU(), "max number of concurrent queries")
+	if err := f.parse(args, 1); err != nil {
+		return err
+	}
+	if *concurrency <= 0 {
+		return fmt.Errorf("serve: -concurrency must be positive")
+	}
+
+	s := &server{indexes: make(map[string]hfmi.FMI), sem: make(chan struct{}, *concurrency), timeout: *timeout}
+	for _, arg := range f.Args() {
+		// name=file, or file named by its base name
+		name, file := strings.TrimSuffix(filepath.Base(arg), filepath.Ext(arg)), arg
+		if i := strings.IndexByte(arg, '='); i >= 0 {
+			name, file = arg[:i], arg[i+1:]
+		}
+		if _, ok := s.indexes[name]; ok {
+			return fmt.Errorf("serve: duplicated index name %s", name)
+		}
+
+		fmi, err := ctor.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("serve: %w", err)
+		}
+		s.indexes[name] = fmi
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: s.handler(), ReadTimeout: *timeout}
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	log.Printf("serving %d indexes on %s", len(s.indexes), *addr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return fmt.Errorf("serve: %w", err)
+	}
+	return nil
+}
+
+// handler routes
+//
+//	GET /indexes
+//	GET /indexes/{name}/count?q=pattern&q=...
+//	GET /indexes/{name}/search?q=pattern&q=...&limit=100
+//	GET /indexes/{name}/extract?from=p&to=q
+//	GET /indexes/{name}/fields?q=pattern&fc=n&limit=100
+//	GET /indexes/{name}/restore?doc=id
+//
+// restore without doc streams the whole text as text/plain, separators are rendered as sep.
+// queries wait for a slot and run until timeout, except the stream, which runs until the client is gone.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/indexes", s.list)
+	mux.HandleFunc("/indexes/", s.query)
+	return mux
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	type index struct {
+		Name      string `json:"name"`
+		Len       uint   `json:"len"`
+		Documents uint   `json:"documents"`
+	}
+
+	ret := make([]index, 0, len(s.indexes))
+	for n, fmi := range s.indexes {
+		ret = append(ret, index{n, fmi.Len(), fmi.Documents()})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+
+	reply(w, http.StatusOK, ret)
+}
+
+func (s *server) query(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		fail(w, http.StatusMethodNotAllowed, errors.New(r.Method))
+		return
+	}
+
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/indexes/"), "/")
+	if len(path) != 2 {
+		fail(w, http.StatusNotFound, errors.New(r.URL.Path))
+		return
+	}
+	fmi, ok := s.indexes[path[0]]
+	if !ok {
+		fail(w, http.StatusNotFound, fmt.Errorf("index %s", path[0]))
+		return
+	}
+
+	// wait for a query slot, until timeout
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	select {
+	case s.sem <- struct{}{}:
+		defer func() { <-s.sem }()
+	case <-ctx.Done():
+		fail(w, http.StatusServiceUnavailable, errBusy)
+		return
+	}
+
+	q := r.URL.Query()
+	switch path[1] {
+	case "count":
+		ret := make([]countResult, 0, len(q["q"]))
+		for _, p := range q["q"] {
+			if err := ctx.Err(); err != nil {
+				fail(w, http.StatusServiceUnavailable, err)
+				return
+			}
+			ret = append(ret, countOf(fmi, p))
+		}
+		reply(w, http.StatusOK, ret)
+	case "search":
+		limit, err := intParam(q.Get("limit"), 100)
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		ret := make([]searchResult, 0, len(q["q"]))
+		for _, p := range q["q"] {
+			r, err := searchOf(ctx, fmi, p, limit)
+			if err != nil {
+				fail(w, http.StatusServiceUnavailable, err)
+				return
+			}
+			ret = append(ret, r)
+		}
+		reply(w, http.StatusOK, ret)
+	case "extract":
+		from, err := intParam(q.Get("from"), 0)
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		to, err := intParam(q.Get("to"), from)
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		if from < 0 || to < 0 {
+			fail(w, http.StatusBadRequest, errors.New("negative position"))
+			return
+		}
+		ret, err := extractOf(fmi, uint(from), uint(to))
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		reply(w, http.StatusOK, ret)
+	case "fields":
+		limit, err := intParam(q.Get("limit"), 100)
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		fc, err := intParam(q.Get("fc"), 1)
+		if err != nil || fc <= 0 {
+			fail(w, http.StatusBadRequest, fmt.Errorf("fc %s", q.Get("fc")))
+			return
+		}
+		ret, err := fieldsOf(ctx, fmi, q.Get("q"), uint(fc), limit)
+		if err == context.DeadlineExceeded || err == context.Canceled {
+			fail(w, http.StatusServiceUnavailable, err)
+			return
+		} else if err != nil {
+			fail(w, http.StatusInternalServerError, err)
+			return
+		}
+		if ret == nil {
+			ret = []fieldsResult{}
+		}
+		reply(w, http.StatusOK, ret)
+	case "restore":
+		s.restore(ctx, w, r, fmi)
+	default:
+		fail(w, http.StatusNotFound, errors.New(r.URL.Path))
+	}
+}
+
+// restore streams the whole text until the client is gone, or restores a document until ctx is done
+func (s *server) restore(ctx context.Context, w http.ResponseWriter, r *http.Request, fmi hfmi.FMI) {
+	q := r.URL.Query()
+	if q.Get("doc") == "" {
+		sep, err := separator(q.Get("sep"))
+		if q.Get("sep") == "" {
+			sep, err = '\n', nil
+		}
+		if err != nil {
+			fail(w, http.StatusBadRequest, err)
+			return
+		}
+		// one query slot, one goroutine
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err = fmi.RestoreTo(r.Context(), w, hfmi.RestoreOptions{One: []byte{sep}, Zero: []byte{'\n'}}); err != nil {
+			log.Printf("restore %s: %v", r.URL.Path, err)
+		}
+		return
+	}
+
+	doc, err := intParam(q.Get("doc"), 0)
+	if err != nil || doc < 0 {
+		fail(w, http.StatusBadRequest, fmt.Errorf("doc %s", q.Get("doc")))
+		return
+	}
+
+	var buf strings.Builder
+	if err = fmi.RestoreDocument(ctx, &buf, uint(doc)); err == hfmi.ErrDocument {
+		fail(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		fail(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	reply(w, http.StatusOK, restoreResult{doc, buf.String()})
+}
+
+func intParam(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
+
+func reply(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func fail(w http.ResponseWriter, code int, err error) {
+	reply(w, code, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
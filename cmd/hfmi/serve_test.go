@@ -0,0 +1,175 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/bench"
+	"github.com/rleiwang/hfmi/ctor"
+	"github.com/rleiwang/hfmi/internal/testutil"
+)
+
+func TestServe(t *testing.T) {
+	text := strings.ReplaceAll(strings.TrimSuffix(text, "\n"), "\n", "\x01")
+	s := &server{
+		indexes: map[string]hfmi.FMI{"t": ctor.New([]byte(text))},
+		sem:     make(chan struct{}, 2),
+		timeout: time.Second,
+	}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		path string
+		code int
+		want string
+	}{
+		{"list", "/indexes", 200, `[{"name":"t","len":34,"documents":3}]`},
+		{"count", "/indexes/t/count?q=cat&q=cow", 200, `[{"pattern":"cat","count":2},{"pattern":"cow","count":0}]`},
+		{"search", "/indexes/t/search?q=dog", 200, `[{"pattern":"dog","range":[19,20],"count":1,"offsets":[16]}]`},
+		{"extract", "/indexes/t/extract?from=0&to=0", 200, `{"from":0,"to":0,"text":"t"}`},
+		{"fields", "/indexes/t/fields?q=dog", 200, `[{"pattern":"dog","position":20,"fields":["the dog ran"]}]`},
+		{"restore doc", "/indexes/t/restore?doc=1", 200, `{"document":1,"text":"the dog ran"}`},
+		{"restore", "/indexes/t/restore?sep=%7C", 200, "the cat sat|the dog ran|a cat ran\n"},
+		{"no doc", "/indexes/t/restore?doc=3", 404, `{"error":"hfmi: document out of range"}`},
+		{"no index", "/indexes/x/count?q=a", 404, `{"error":"index x"}`},
+		{"bad param", "/indexes/t/extract?from=a", 400, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			if got := strings.TrimSuffix(string(body), "\n"); resp.StatusCode != tt.code || (tt.want != "" && got != strings.TrimSuffix(tt.want, "\n")) {
+				t.Errorf("GET %s = %d %s, want %d %s", tt.path, resp.StatusCode, got, tt.code, tt.want)
+			}
+		})
+	}
+
+	// all query slots are taken
+	s.sem <- struct{}{}
+	s.sem <- struct{}{}
+	s.timeout = 10 * time.Millisecond
+	busy := httptest.NewServer(s.handler())
+	defer busy.Close()
+	if resp, err := http.Get(busy.URL + "/indexes/t/count?q=a"); err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GET busy = %v, %v, want %d", resp, err, http.StatusServiceUnavailable)
+	}
+}
+
+func TestServeStream(t *testing.T) {
+	c, err := bench.Generate("english", 1<<18, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.ReplaceAll(string(c.Text), "\x01", "\n") + "\n"
+
+	// restore streams longer than the query timeout
+	s := &server{
+		indexes: map[string]hfmi.FMI{"t": ctor.New(append([]byte(nil), c.Text...))},
+		sem:     make(chan struct{}, 1),
+		timeout: time.Millisecond,
+	}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/indexes/t/restore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != want {
+		t.Errorf("GET restore = %d, %d bytes, want %d bytes", resp.StatusCode, len(body), len(want))
+	}
+}
+
+// TestServeParallel concurrent requests query one index of sparse blocks, see -race
+func TestServeParallel(t *testing.T) {
+	text := testutil.Skewed(5, 1<<15)
+	docs := bytes.Split(text, []byte{1})
+	s := &server{
+		indexes: map[string]hfmi.FMI{"t": ctor.New(append([]byte(nil), text...))},
+		sem:     make(chan struct{}, 16),
+		timeout: 10 * time.Second,
+	}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := i; j < len(docs); j += cap(errs) {
+				var got struct {
+					Text string `json:"text"`
+				}
+				resp, err := http.Get(fmt.Sprintf("%s/indexes/t/restore?doc=%d", ts.URL, j))
+				if err == nil {
+					err = json.NewDecoder(resp.Body).Decode(&got)
+					resp.Body.Close()
+				}
+				if err != nil || got.Text != string(docs[j]) {
+					errs <- fmt.Errorf("GET restore?doc=%d = %q, %v, want %q", j, got.Text, err, docs[j])
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestQueryCanceled(t *testing.T) {
+	fmi := ctor.New([]byte(strings.ReplaceAll(strings.TrimSuffix(text, "\n"), "\n", "\x01")))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if r, err := searchOf(ctx, fmi, "cat", -1); err != context.Canceled {
+		t.Errorf("searchOf() = %v, %v, want %v", r, err, context.Canceled)
+	}
+	if r, err := fieldsOf(ctx, fmi, "cat", 1, -1); err != context.Canceled {
+		t.Errorf("fieldsOf() = %v, %v, want %v", r, err, context.Canceled)
+	}
+	if r, err := searchOf(context.Background(), fmi, "cow", -1); err != nil || r.Count != 0 {
+		t.Errorf("searchOf() = %v, %v", r, err)
+	}
+}
@@ -0,0 +1,328 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package collection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+var (
+	// ErrDocument document is empty or contains byte 0 or 1
+	ErrDocument = errors.New("collection: invalid document")
+
+	// ErrDeleted document is deleted
+	ErrDeleted = errors.New("collection: document deleted")
+
+	// DefaultOptions merges a segment into the preceding one, which is no larger,
+	// segments are merged like a binary counter, there are O(log n) segments of n equal sized batches
+	DefaultOptions = Options{MergeFactor: 1}
+)
+
+// Options of collection
+type Options struct {
+	// MergeFactor, the newest segment is merged into the preceding one,
+	// while the preceding one is at most MergeFactor times as large, 0 never merges
+	MergeFactor uint
+}
+
+// Hit occurrence of pattern in document, Offset is the text offset in collection
+type Hit struct {
+	Document uint
+	Offset   uint
+}
+
+// Collection append-only documents, indexed by segments in order of addition.
+// New documents are indexed in a fresh small segment, segments are merged by BWT merging,
+// the text of collection is the text of segments separated by byte 1.
+// Deleted documents are marked by tombstones, and dropped by Compact.
+// Queries are safe to run concurrently with Add, Delete and Compact.
+type Collection struct {
+	opts Options
+
+	// mu serializes Add, Delete and Compact
+	mu sync.Mutex
+
+	// rw guards snap, which is copy on write
+	rw   sync.RWMutex
+	snap snapshot
+}
+
+// snapshot segments and tombstones keyed by document id
+type snapshot struct {
+	segs []*segment
+	dead tombstones
+}
+
+// New returns empty collection
+func New(opts Options) *Collection {
+	return &Collection{opts: opts}
+}
+
+// Add indexes docs in a new segment, then merges segments by opts
+func (c *Collection) Add(docs ...[]byte) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	for i, d := range docs {
+		if len(d) == 0 || bytes.IndexByte(d, 0) >= 0 || bytes.IndexByte(d, 1) >= 0 {
+			return fmt.Errorf("%w: %d", ErrDocument, i)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snap := c.load()
+	segs := append(snap.segs, newSegment(ctor.New(bytes.Join(docs, []byte{1}))))
+	for n := len(segs); n > 1 && segs[n-2].Len() <= c.opts.MergeFactor*segs[n-1].Len(); n = len(segs) {
+		segs = append(segs[:n-2], merge(segs[n-2], segs[n-1]))
+	}
+	c.store(snapshot{segs, snap.dead})
+
+	return nil
+}
+
+// Delete marks id-th document deleted, it is no longer found by queries, and dropped by the next Compact
+func (c *Collection) Delete(id uint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snap := c.load()
+	if id >= snap.documents() {
+		return hfmi.ErrDocument
+	}
+	if !snap.dead.has(id) {
+		c.store(snapshot{snap.segs, snap.dead.with(id)})
+	}
+	return nil
+}
+
+// Deleted returns true if id-th document is deleted
+func (c *Collection) Deleted(id uint) bool {
+	return c.load().dead.has(id)
+}
+
+// Compact rebuilds segments with deleted documents from the remaining documents, then merges all segments
+// into one. Document ids following deleted documents are renumbered, tombstones are cleared.
+func (c *Collection) Compact(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snap := c.load()
+	var segs []*segment
+	id := uint(0)
+	for _, s := range snap.segs {
+		n := s.Documents()
+		if snap.dead.any(id, id+n) {
+			var err error
+			if s, err = rebuild(ctx, s, id, snap.dead); err != nil {
+				return err
+			}
+		}
+		if id += n; s != nil {
+			segs = append(segs, s)
+		}
+	}
+
+	for len(segs) > 1 {
+		segs = append([]*segment{merge(segs[0], segs[1])}, segs[2:]...)
+	}
+	c.store(snapshot{segs: segs})
+
+	return nil
+}
+
+// rebuild returns segment of documents of s which are not deleted, nil if all are deleted
+func rebuild(ctx context.Context, s *segment, base uint, dead tombstones) (*segment, error) {
+	var text bytes.Buffer
+	for id := uint(0); id < s.Documents(); id++ {
+		if dead.has(base + id) {
+			continue
+		}
+		if text.Len() > 0 {
+			text.WriteByte(1)
+		}
+		if err := s.RestoreDocument(ctx, &text, id); err != nil {
+			return nil, err
+		}
+	}
+	if text.Len() == 0 {
+		return nil, nil
+	}
+	return newSegment(ctor.New(text.Bytes())), nil
+}
+
+// Segments returns segments in order of addition, the oldest first
+func (c *Collection) Segments() []hfmi.FMI {
+	segs := c.load().segs
+	ret := make([]hfmi.FMI, len(segs))
+	for i, s := range segs {
+		ret[i] = s.FMI
+	}
+	return ret
+}
+
+// Len returns the same as FMI.Len of all segments merged, the end of each segment but the last is a separator
+func (c *Collection) Len() uint {
+	n := uint(0)
+	for _, s := range c.load().segs {
+		n += s.Len()
+	}
+	return n
+}
+
+// Documents returns the number of document ids, including deleted documents
+func (c *Collection) Documents() uint {
+	return c.load().documents()
+}
+
+// Live returns ids of documents which are not deleted, in ascending order
+func (c *Collection) Live() []uint {
+	snap := c.load()
+	n := snap.documents()
+	ids := make([]uint, 0, n)
+	for id := uint(0); id < n; id++ {
+		if !snap.dead.has(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Count returns the number of pattern occurrences in documents which are not deleted.
+// Occurrences spanning documents of different segments are not counted.
+func (c *Collection) Count(p string) uint {
+	snap := c.load()
+	n := uint(0)
+	snap.each(func(s *segment, id, off uint) {
+		if !snap.dead.any(id, id+s.Documents()) {
+			n += s.Count(p)
+			return
+		}
+		for _, o := range s.Occurrences(p) {
+			if !snap.dead.has(id + s.document(o)) {
+				n++
+			}
+		}
+	})
+	return n
+}
+
+// Search returns occurrences of pattern in documents which are not deleted, in ascending order of offset
+func (c *Collection) Search(p string) []Hit {
+	snap := c.load()
+	var hits []Hit
+	snap.each(func(s *segment, id, off uint) {
+		for _, o := range s.Occurrences(p) {
+			if doc := id + s.document(o); !snap.dead.has(doc) {
+				hits = append(hits, Hit{doc, off + o})
+			}
+		}
+	})
+	return hits
+}
+
+// Occurrences returns text offsets of pattern occurrences in documents which are not deleted, in ascending order
+func (c *Collection) Occurrences(p string) []uint {
+	var ret []uint
+	for _, h := range c.Search(p) {
+		ret = append(ret, h.Offset)
+	}
+	return ret
+}
+
+// RestoreDocument writes id-th document to w, id is zero based in order of addition
+func (c *Collection) RestoreDocument(ctx context.Context, w io.Writer, id uint) error {
+	snap := c.load()
+	if snap.dead.has(id) {
+		return ErrDeleted
+	}
+	for _, s := range snap.segs {
+		if n := s.Documents(); id >= n {
+			id -= n
+			continue
+		}
+		return s.RestoreDocument(ctx, w, id)
+	}
+	return hfmi.ErrDocument
+}
+
+// Restore writes documents which are not deleted to w, separated by sep
+func (c *Collection) Restore(ctx context.Context, w io.Writer, sep []byte) error {
+	snap := c.load()
+	first := true
+	var err error
+	snap.each(func(s *segment, id, off uint) {
+		for i := uint(0); i < s.Documents() && err == nil; i++ {
+			if snap.dead.has(id + i) {
+				continue
+			}
+			if !first {
+				_, err = w.Write(sep)
+			}
+			if first = false; err == nil {
+				err = s.RestoreDocument(ctx, w, i)
+			}
+		}
+	})
+	return err
+}
+
+// documents returns the number of document ids
+func (s snapshot) documents() uint {
+	n := uint(0)
+	for _, seg := range s.segs {
+		n += seg.Documents()
+	}
+	return n
+}
+
+// each calls f of each segment, with the id and text offset of its first document
+func (s snapshot) each(f func(seg *segment, id, off uint)) {
+	id, off := uint(0), uint(0)
+	for _, seg := range s.segs {
+		f(seg, id, off)
+		id += seg.Documents()
+		off += seg.Len()
+	}
+}
+
+// load returns snapshot, segments have no spare capacity, appending to them doesn't race
+func (c *Collection) load() snapshot {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+	snap := c.snap
+	snap.segs = snap.segs[:len(snap.segs):len(snap.segs)]
+	return snap
+}
+
+func (c *Collection) store(snap snapshot) {
+	c.rw.Lock()
+	c.snap = snap
+	c.rw.Unlock()
+}
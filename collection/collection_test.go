@@ -0,0 +1,169 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package collection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+func TestCollection(t *testing.T) {
+	c := New(DefaultOptions)
+
+	var docs [][]byte
+	for i := 0; i < 40; i++ {
+		batch := [][]byte{[]byte(fmt.Sprintf("doc %d of batch %d", i*2, i)), []byte(fmt.Sprintf("doc %d says hello", i*2+1))}
+		if err := c.Add(batch...); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		docs = append(docs, batch...)
+	}
+	if n := len(c.Segments()); n < 2 || n > 10 {
+		t.Errorf("Segments() = %v segments", n)
+	}
+
+	text := bytes.Join(docs, []byte{1})
+	want := ctor.New(append([]byte{}, text...))
+	check := func(t *testing.T) {
+		if c.Len() != want.Len() || c.Documents() != want.Documents() {
+			t.Errorf("Len(), Documents() = %v, %v, want %v, %v", c.Len(), c.Documents(), want.Len(), want.Documents())
+		}
+		for _, p := range []string{"doc", "hello", "batch 3", "9 ", "missing"} {
+			if got := c.Count(p); got != want.Count(p) {
+				t.Errorf("Count(%q) = %v, want %v", p, got, want.Count(p))
+			}
+			if got := c.Occurrences(p); !reflect.DeepEqual(got, want.Occurrences(p)) {
+				t.Errorf("Occurrences(%q) = %v, want %v", p, got, want.Occurrences(p))
+			}
+		}
+		for _, id := range []uint{0, 7, 42, 79} {
+			var b bytes.Buffer
+			if err := c.RestoreDocument(context.Background(), &b, id); err != nil || !bytes.Equal(b.Bytes(), docs[id]) {
+				t.Errorf("RestoreDocument(%d) = %q, %v, want %q", id, b.Bytes(), err, docs[id])
+			}
+		}
+		if err := c.RestoreDocument(context.Background(), &bytes.Buffer{}, 80); !errors.Is(err, hfmi.ErrDocument) {
+			t.Errorf("RestoreDocument() error = %v, want %v", err, hfmi.ErrDocument)
+		}
+	}
+
+	t.Run("segments", check)
+
+	if err := c.Compact(context.Background()); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	t.Run("compacted", func(t *testing.T) {
+		if segs := c.Segments(); len(segs) != 1 || !bytes.Equal(segs[0].Bytes(), want.Bytes()) {
+			t.Errorf("Compact() differs from New()")
+		}
+		check(t)
+	})
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name string
+		docs [][]byte
+		err  error
+	}{
+		{"none", nil, nil},
+		{"empty", [][]byte{[]byte("a"), {}}, ErrDocument},
+		{"separator", [][]byte{[]byte("a\x01b")}, ErrDocument},
+		{"zero", [][]byte{[]byte("a\x00")}, ErrDocument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(Options{})
+			if err := c.Add(tt.docs...); !errors.Is(err, tt.err) {
+				t.Errorf("Add() error = %v, want %v", err, tt.err)
+			}
+			if len(c.Segments()) != 0 {
+				t.Errorf("Add() = %v segments, want none", len(c.Segments()))
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c := New(DefaultOptions)
+	for _, batch := range [][]string{{"login ok", "login failed"}, {"logout"}, {"disk full", "login failed again"}} {
+		var docs [][]byte
+		for _, d := range batch {
+			docs = append(docs, []byte(d))
+		}
+		if err := c.Add(docs...); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	for _, id := range []uint{1, 2} {
+		if err := c.Delete(id); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+	}
+	if err := c.Delete(5); !errors.Is(err, hfmi.ErrDocument) {
+		t.Errorf("Delete() error = %v, want %v", err, hfmi.ErrDocument)
+	}
+
+	check := func(t *testing.T, live []uint, hits []Hit, deleted uint, text string) {
+		if got := c.Live(); !reflect.DeepEqual(got, live) {
+			t.Errorf("Live() = %v, want %v", got, live)
+		}
+		if got := c.Count("log"); got != uint(len(hits)) {
+			t.Errorf("Count() = %v, want %v", got, len(hits))
+		}
+		if got := c.Search("log"); !reflect.DeepEqual(got, hits) {
+			t.Errorf("Search() = %v, want %v", got, hits)
+		}
+		if err := c.RestoreDocument(context.Background(), &bytes.Buffer{}, deleted); !errors.Is(err, ErrDeleted) {
+			t.Errorf("RestoreDocument() error = %v, want %v", err, ErrDeleted)
+		}
+
+		var b bytes.Buffer
+		if err := c.Restore(context.Background(), &b, []byte{'\n'}); err != nil || b.String() != text {
+			t.Errorf("Restore() = %q, %v, want %q", b.String(), err, text)
+		}
+	}
+
+	t.Run("tombstones", func(t *testing.T) {
+		check(t, []uint{0, 3, 4}, []Hit{{0, 0}, {4, 39}}, 1, "login ok\ndisk full\nlogin failed again")
+	})
+
+	if err := c.Compact(context.Background()); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	t.Run("compacted", func(t *testing.T) {
+		if c.Len() != uint(len("login ok\x01disk full\x01login failed again"))+1 || len(c.Segments()) != 1 {
+			t.Errorf("Compact() = %v segments of %v", len(c.Segments()), c.Len())
+		}
+		if c.Deleted(1) {
+			t.Errorf("Deleted() = true after Compact()")
+		}
+		c.Delete(1)
+		check(t, []uint{0, 2}, []Hit{{0, 0}, {2, 19}}, 1, "login ok\nlogin failed again")
+	})
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package collection
+
+import (
+	"sort"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+// segment index of documents, with offsets of separators
+type segment struct {
+	hfmi.FMI
+
+	// seps text offsets of separators in ascending order, derived from the separator bucket,
+	// whose rows are in text order
+	seps []uint
+}
+
+func newSegment(fmi hfmi.FMI) *segment {
+	seps := make([]uint, 0, fmi.Documents()-1)
+	if s, e, ok := fmi.GetBound(1); ok {
+		for p := s + 1; p <= e; p++ {
+			o, _ := fmi.Offset(p)
+			seps = append(seps, o)
+		}
+	}
+	return &segment{FMI: fmi, seps: seps}
+}
+
+// merge returns segment of a + byte 1 + b, the end of a becomes a separator
+func merge(a, b *segment) *segment {
+	seps := make([]uint, 0, len(a.seps)+len(b.seps)+1)
+	seps = append(append(seps, a.seps...), a.Len()-1)
+	for _, o := range b.seps {
+		seps = append(seps, a.Len()+o)
+	}
+	return &segment{FMI: ctor.Merge(a.FMI, b.FMI), seps: seps}
+}
+
+// document returns document id in segment of text offset o
+func (s *segment) document(o uint) uint {
+	return uint(sort.Search(len(s.seps), func(i int) bool { return s.seps[i] >= o }))
+}
+
+// tombstones bitmap of deleted document ids
+type tombstones []uint64
+
+func (t tombstones) has(id uint) bool {
+	return id/64 < uint(len(t)) && t[id/64]&(1<<(id%64)) != 0
+}
+
+// with returns copy of t with id set
+func (t tombstones) with(id uint) tombstones {
+	n := uint(len(t))
+	if id/64 >= n {
+		n = id/64 + 1
+	}
+	c := make(tombstones, n)
+	copy(c, t)
+	c[id/64] |= 1 << (id % 64)
+	return c
+}
+
+// any returns true if any id in [from, to) is set
+func (t tombstones) any(from, to uint) bool {
+	for id := from; id < to; id++ {
+		if id/64 >= uint(len(t)) {
+			return false
+		}
+		if id%64 == 0 && t[id/64] == 0 {
+			id += 63
+			continue
+		}
+		if t.has(id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package cst
+
+import (
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/internal/lcp"
+)
+
+// SuffixTree compressed suffix tree on top of FM-index, with compressed LCP and range minimum query.
+// note: BWT of the index sorts prefixes of text, hence the tree is built on reversed text,
+// path label of a node is the longest common suffix of prefixes (rows) under it, returned in text order.
+// separators (byte 0 and 1) are never part of a path label.
+type SuffixTree struct {
+	fmi hfmi.FMI
+	lcp *lcp.LCP
+	lrs uint // row of the longest repeated substring
+	sus uint // row of the shortest unique substring
+	sul uint // length of the shortest unique substring, zero if none
+}
+
+// Node is lcp interval, rows [L, R] of BWT
+type Node struct {
+	L, R uint
+}
+
+// New build suffix tree of the index, it walks the whole text three times,
+// only the lcp array is kept, the text is copied during the build of it
+func New(fmi hfmi.FMI) *SuffixTree {
+	t := &SuffixTree{fmi: fmi, lcp: lcp.New(fmi)}
+
+	// longest repeated substring is the maximal lcp, the leftmost row on tie
+	// shortest unique substring, d -> length of the prefix back to the separator
+	n := fmi.Len()
+	for i, p, d, sep, best := uint(0), uint(0), uint(0), uint(0), uint(0); i+1 < n; i++ {
+		a, r, _ := fmi.Access(p)
+		if a == 1 {
+			// note: separators are in text order in F, but not in L
+			sep++
+			r = sep
+		}
+		s, _, _ := fmi.GetBound(a)
+		if p = s + r; a < 2 {
+			d = 0
+			continue
+		}
+		d++
+
+		v := t.lcp.PLCP(i)
+		if v > best || (v == best && v > 0 && p < t.lrs) {
+			best, t.lrs = v, p
+		}
+		if v+1 > d || (t.sul != 0 && v+1 >= t.sul) {
+			continue
+		}
+		l := max(v, t.lcp.At(p+1)) + 1
+		if l <= d && (t.sul == 0 || l < t.sul) {
+			t.sus, t.sul = p, l
+		}
+	}
+
+	return t
+}
+
+// Root returns the root node
+func (t *SuffixTree) Root() Node {
+	return Node{0, t.lcp.Len() - 1}
+}
+
+// Leaf returns the leaf of row p
+func (t *SuffixTree) Leaf(p uint) (Node, bool) {
+	return Node{p, p}, p < t.lcp.Len()
+}
+
+// IsLeaf returns true if v is a leaf
+func (t *SuffixTree) IsLeaf(v Node) bool {
+	return v.L == v.R
+}
+
+// LCP returns the length of the longest common suffix of prefixes at row p-1 and p, separators excluded
+func (t *SuffixTree) LCP(p uint) uint {
+	return t.lcp.At(p)
+}
+
+// StringDepth returns length of the path label of v
+func (t *SuffixTree) StringDepth(v Node) uint {
+	if v.L == v.R {
+		return t.leafDepth(v.L)
+	}
+	return t.lcp.At(t.lcp.RMQ(v.L+1, v.R))
+}
+
+// Parent returns parent of v, false if v is root
+func (t *SuffixTree) Parent(v Node) (Node, bool) {
+	if v == t.Root() {
+		return v, false
+	}
+
+	return t.expand(v.L, v.R, max(t.lcp.At(v.L), t.lcp.At(v.R+1))), true
+}
+
+// Children returns children of v in lexicographic order of the reversed text
+func (t *SuffixTree) Children(v Node) []Node {
+	if v.L == v.R {
+		return nil
+	}
+
+	var children []Node
+	d, l := t.StringDepth(v), v.L
+	for l < v.R {
+		m := t.lcp.RMQ(l+1, v.R)
+		if t.lcp.At(m) != d {
+			break
+		}
+		children = append(children, Node{l, m - 1})
+		l = m
+	}
+
+	return append(children, Node{l, v.R})
+}
+
+// LCA returns the lowest common ancestor of u and v
+func (t *SuffixTree) LCA(u, v Node) Node {
+	if u.L > v.L {
+		u, v = v, u
+	}
+	if v.R <= u.R {
+		// u contains v
+		return u
+	}
+
+	return t.expand(u.L, v.R, t.lcp.At(t.lcp.RMQ(u.L+1, v.R)))
+}
+
+// Label returns path label of v in text order
+func (t *SuffixTree) Label(v Node) []byte {
+	return t.label(v.L, t.StringDepth(v))
+}
+
+// LongestRepeatedSubstring returns the longest substring occurs at least twice, and its node
+func (t *SuffixTree) LongestRepeatedSubstring() ([]byte, Node) {
+	d := t.lcp.At(t.lrs)
+	if d == 0 {
+		return nil, t.Root()
+	}
+	return t.label(t.lrs, d), t.expand(t.lrs-1, t.lrs, d)
+}
+
+// ShortestUniqueSubstring returns the shortest substring occurs exactly once, and its leaf
+func (t *SuffixTree) ShortestUniqueSubstring() ([]byte, Node, bool) {
+	if t.sul == 0 {
+		return nil, Node{}, false
+	}
+	return t.label(t.sus, t.sul), Node{t.sus, t.sus}, true
+}
+
+// expand returns the lcp interval of depth d containing rows [l, r]
+func (t *SuffixTree) expand(l, r, d uint) Node {
+	return Node{t.lcp.PSV(l, d), t.lcp.NSV(r, d) - 1}
+}
+
+// label returns the last d chars of prefix at row p
+func (t *SuffixTree) label(p, d uint) []byte {
+	buf := make([]byte, d)
+	for i := d; i > 0; i-- {
+		b, r, _ := t.fmi.Locate(p)
+		buf[i-1] = b
+		p, _ = t.fmi.Select(b, r)
+	}
+
+	return buf
+}
+
+// leafDepth returns length of prefix at row p back to the separator
+func (t *SuffixTree) leafDepth(p uint) uint {
+	d := uint(0)
+	for {
+		b, r, ok := t.fmi.Locate(p)
+		if !ok || b < 2 {
+			return d
+		}
+		d++
+		p, _ = t.fmi.Select(b, r)
+	}
+}
+
+func max(a, b uint) uint {
+	if a > b {
+		return a
+	}
+	return b
+}
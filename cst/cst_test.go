@@ -0,0 +1,171 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package cst
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/ctor"
+	"github.com/rleiwang/hfmi/internal/testutil"
+)
+
+func TestLCP(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"textbook", args{"tobeornottobethatisthequestion"}},
+		{"fields", args{"banana\x01ananas\x01bandana\x01nab"}},
+		{"repeats", args{strings.Repeat("abcab", 100)}},
+		{"words", args{string(testutil.WordsOf(1, 500, 6, "abcd", 1))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fmi := ctor.New([]byte(tt.args.t))
+			st := New(fmi)
+
+			// rows -> prefixes
+			var prev []byte
+			v := make([]uint, fmi.Len())
+			for i := uint(0); i < fmi.Len(); i++ {
+				cur := prefix(st, i)
+				want := uint(0)
+				for i > 0 && want < uint(len(cur)) && want < uint(len(prev)) &&
+					cur[len(cur)-1-int(want)] == prev[len(prev)-1-int(want)] {
+					want++
+				}
+				if v[i] = st.LCP(i); v[i] != want {
+					t.Errorf("LCP(%v) = %v, want %v, %q, %q", i, v[i], want, prev, cur)
+				}
+				prev = cur
+			}
+
+			// note: lcp at a row walks to a sampled offset, step wider on large texts
+			step := 1 + fmi.Len()/100
+			for i := uint(0); i < fmi.Len(); i += step {
+				for j := i; j < fmi.Len(); j += step + 6 {
+					want := i
+					for k := i; k <= j; k++ {
+						if v[k] < v[want] {
+							want = k
+						}
+					}
+					if got := st.lcp.RMQ(i, j); got != want {
+						t.Errorf("rmq(%v, %v) = %v, want %v", i, j, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSubstrings(t *testing.T) {
+	type args struct {
+		t string
+	}
+	tests := []struct {
+		name string
+		args args
+		lrs  string
+		sus  int
+	}{
+		{"textbook", args{"tobeornottobethatisthequestion"}, "tobe", 1},
+		{"fields", args{"banana\x01ananas\x01bandana\x01nab"}, "anana", 1},
+		{"repeats", args{strings.Repeat("ab", 10)}, strings.Repeat("ab", 9), 18},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := New(ctor.New([]byte(tt.args.t)))
+			if got, _ := st.LongestRepeatedSubstring(); string(got) != tt.lrs {
+				t.Errorf("LongestRepeatedSubstring() = %q, want %q", got, tt.lrs)
+			}
+
+			got, _, ok := st.ShortestUniqueSubstring()
+			if !ok || len(got) != tt.sus || strings.Count(tt.args.t, string(got)) != 1 {
+				t.Errorf("ShortestUniqueSubstring() = %q, %v, want length %v", got, ok, tt.sus)
+			}
+		})
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	st := New(empty{})
+	if got, v := st.LongestRepeatedSubstring(); got != nil || v != st.Root() {
+		t.Errorf("LongestRepeatedSubstring() = %q, %v", got, v)
+	}
+	if got, _, ok := st.ShortestUniqueSubstring(); ok {
+		t.Errorf("ShortestUniqueSubstring() = %q, %v", got, ok)
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	st := New(ctor.New([]byte("banana\x01ananas\x01bandana\x01nab")))
+
+	var walk func(v Node)
+	walk = func(v Node) {
+		children := st.Children(v)
+		if len(children) == 0 {
+			return
+		}
+		if len(children) < 2 || children[0].L != v.L || children[len(children)-1].R != v.R {
+			t.Errorf("Children(%v) = %v", v, children)
+		}
+		for _, c := range children {
+			if p, ok := st.Parent(c); !ok || p != v {
+				t.Errorf("Parent(%v) = %v, %v, want %v", c, p, ok, v)
+			}
+			if st.StringDepth(c) < st.StringDepth(v) {
+				t.Errorf("StringDepth(%v) = %v < %v", c, st.StringDepth(c), st.StringDepth(v))
+			}
+			if !bytes.HasSuffix(st.Label(c), st.Label(v)) {
+				t.Errorf("Label(%v) = %q, parent %q", c, st.Label(c), st.Label(v))
+			}
+			walk(c)
+		}
+	}
+	walk(st.Root())
+
+	u, _ := st.Leaf(3)
+	v, _ := st.Leaf(20)
+	lca := st.LCA(u, v)
+	if lca.L > 3 || lca.R < 20 || st.StringDepth(lca) != st.lcp.At(st.lcp.RMQ(4, 20)) {
+		t.Errorf("LCA(%v, %v) = %v", u, v, lca)
+	}
+}
+
+// empty index without text
+type empty struct {
+	hfmi.FMI
+}
+
+func (empty) Len() uint {
+	return 0
+}
+
+// prefix returns prefix of row p back to the separator
+func prefix(st *SuffixTree, p uint) []byte {
+	return st.label(p, st.leafDepth(p))
+}
@@ -21,21 +21,52 @@ package ctor
 
 import (
 	"github.com/rleiwang/hfmi"
-	"github.com/rleiwang/hfmi/internal"
 	"github.com/rleiwang/hfmi/internal/hybrid"
+	"github.com/rleiwang/hfmi/internal/wavelet"
 )
 
-// New construct FM-Index from BWT
+// New construct FM-Index from BWT, t must pass ValidateText, New panics otherwise
 func New(t []byte) hfmi.FMI {
 	return hybrid.New(t)
 }
 
-// Build restore serialized FM-index from bytes
+// ValidateText checks text of documents separated by byte 1, documents must not be empty nor contain byte 0
+func ValidateText(t []byte) error {
+	return hybrid.ValidateText(t)
+}
+
+// NewInts construct FM-Index of integer sequence, e.g. token ids
+func NewInts(t []uint32) hfmi.IntFMI {
+	return wavelet.New(t)
+}
+
+// BuildInts restore serialized FM-index of integer sequence from bytes of IntFMI.Bytes(), d must pass ValidateInts
+func BuildInts(d []byte) hfmi.IntFMI {
+	return wavelet.Build(d)
+}
+
+// ValidateInts checks serialized FM-index of integer sequence, BuildInts panics on malformed bytes
+func ValidateInts(d []byte) error {
+	return wavelet.Validate(d)
+}
+
+// Merge construct FM-Index of text a + byte 1 + text b, by merging FM-Index of a and b
+func Merge(a, b hfmi.FMI) hfmi.FMI {
+	return hybrid.Merge(a, b)
+}
+
+// Build restore serialized FM-index from bytes of FMI.Bytes(), including the uint32 layout of version 0,
+// d must pass Validate
 func Build(cnt uint, ridx, d []byte) hfmi.FMI {
 	return hybrid.Build(cnt, ridx, d)
 }
 
-// SetSegmentCache must set to a value corresponds to the number of processing goroutines
-func SetSegmentCache(sz uint) {
-	internal.InitSegmentCache(uint64(sz))
+// Validate checks serialized FM-index, Build panics on malformed bytes
+func Validate(cnt uint, ridx, d []byte) error {
+	return hybrid.Validate(cnt, ridx, d)
 }
+
+// SetSegmentCache is a no-op, builders no longer share a segment cache.
+//
+// Deprecated: it needn't be called before New, Merge or FromCSV.
+func SetSegmentCache(sz uint) {}
@@ -0,0 +1,134 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package ctor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+
+	"github.com/rleiwang/hfmi"
+)
+
+const (
+	// Sep separates fields and records of CSV
+	Sep = byte(1)
+	// Escape escapes payload bytes collide with separators or itself, and empty field,
+	// escaped payloads are non-printable bytes in (Sep, Escape], a printable pattern never matches them
+	Escape = byte(5)
+	// empty field, note: BWT doesn't support consecutive separators
+	empty = byte(4)
+)
+
+// ErrEmptyCSV CSV has no record
+var ErrEmptyCSV = errors.New("ctor: empty csv")
+
+// CSVOptions options of CSV parser, zero value is RFC 4180 CSV
+type CSVOptions struct {
+	Comma            rune // field delimiter, ',' by default, '\t' for TSV
+	Comment          rune // comment character, lines start with it are ignored
+	LazyQuotes       bool // a quote may appear in an unquoted field, a non-doubled quote may appear in a quoted field
+	TrimLeadingSpace bool // leading white space in a field is ignored
+}
+
+// FromCSV construct FM-Index from CSV, fields and records are all separated by byte 1,
+// bytes 0, 1, Escape in fields and empty fields are escaped, check Unescape.
+// returns index, and number of fields per record for ExtractAllFields
+func FromCSV(r io.Reader, opts CSVOptions) (hfmi.FMI, uint, error) {
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.Comment = opts.Comment
+	cr.LazyQuotes = opts.LazyQuotes
+	cr.TrimLeadingSpace = opts.TrimLeadingSpace
+	cr.ReuseRecord = true
+
+	var buf bytes.Buffer
+	fc := uint(0)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, 0, err
+		}
+		fc = uint(len(rec))
+
+		for _, f := range rec {
+			if buf.Len() > 0 {
+				buf.WriteByte(Sep)
+			}
+			escape(&buf, f)
+		}
+	}
+
+	if buf.Len() == 0 {
+		return nil, 0, ErrEmptyCSV
+	}
+
+	return New(buf.Bytes()), fc, nil
+}
+
+// Unescape restores field escaped by FromCSV
+func Unescape(f []byte) []byte {
+	if bytes.IndexByte(f, Escape) < 0 {
+		return f
+	}
+
+	ret := make([]byte, 0, len(f))
+	for i := 0; i < len(f); i++ {
+		if f[i] != Escape || i+1 == len(f) {
+			ret = append(ret, f[i])
+			continue
+		}
+		i++
+		switch p := f[i]; {
+		case p == empty:
+		case p < empty:
+			// byte 0 or 1
+			ret = append(ret, p-2)
+		default:
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+func escape(buf *bytes.Buffer, f string) {
+	if len(f) == 0 {
+		buf.WriteByte(Escape)
+		buf.WriteByte(empty)
+		return
+	}
+
+	for i := 0; i < len(f); i++ {
+		if f[i] < 2 {
+			buf.WriteByte(Escape)
+			buf.WriteByte(f[i] + 2)
+		} else if f[i] == Escape {
+			buf.WriteByte(Escape)
+			buf.WriteByte(Escape)
+		} else {
+			buf.WriteByte(f[i])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package ctor
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFromCSV(t *testing.T) {
+	type args struct {
+		csv  string
+		opts CSVOptions
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"plain", args{"id,name\n1,alice\n2,bob\n", CSVOptions{}}},
+		{"quoted", args{"id,note\n1,\"a, b\"\n2,\"line\nbreak\"\n3,\"say \"\"hi\"\"\"\n", CSVOptions{}}},
+		{"empty", args{",a,\n,,\nb,,c\n", CSVOptions{}}},
+		{"reserved", args{"a\x00b,\x01,\x02\x05\x04\nx,y,z\n", CSVOptions{}}},
+		{"tsv", args{"a\tb c\n# comment\nd\te,f\n", CSVOptions{Comma: '\t', Comment: '#'}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := csv.NewReader(strings.NewReader(tt.args.csv))
+			if tt.args.opts.Comma != 0 {
+				cr.Comma = tt.args.opts.Comma
+			}
+			cr.Comment = tt.args.opts.Comment
+			want, err := cr.ReadAll()
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+
+			fmi, fc, err := FromCSV(strings.NewReader(tt.args.csv), tt.args.opts)
+			if err != nil || fc != uint(len(want[0])) {
+				t.Fatalf("FromCSV() = %v, %v, want %v, nil", fc, err, len(want[0]))
+			}
+			rows, ok := fmi.ExtractAllFields(Sep, fc)
+			if !ok {
+				t.Fatalf("ExtractAllFields() failed")
+			}
+			got := make([][]string, len(rows))
+			for i, row := range rows {
+				for _, f := range row {
+					got[i] = append(got[i], string(Unescape(f)))
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ExtractAllFields() = %q, want %q", got, want)
+			}
+
+			// printable patterns never match escapes
+			var all strings.Builder
+			for _, rec := range want {
+				for _, f := range rec {
+					all.WriteString(f)
+					all.WriteByte(0)
+				}
+			}
+			for c := byte(' '); c <= '~'; c++ {
+				if got, want := fmi.Count(string(c)), strings.Count(all.String(), string(c)); got != uint(want) {
+					t.Errorf("Count(%q) = %v, want %v", c, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFromCSVError(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+	}{
+		{"ragged", "a,b\nc\n"},
+		{"quote", "a,\"b\n"},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := FromCSV(strings.NewReader(tt.csv), CSVOptions{}); err == nil {
+				t.Errorf("FromCSV(%q) error = nil", tt.csv)
+			}
+		})
+	}
+}
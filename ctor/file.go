@@ -0,0 +1,142 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package ctor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/internal/hybrid"
+)
+
+// index file: magic, version, text len (uint64), dictionary len (uint16), dictionary, FMI.Bytes()
+var magic = []byte("HFMI")
+
+// index file of integer sequence: intsMagic, version, IntFMI.Bytes()
+var intsMagic = []byte("HFMN")
+
+// version of index files, FMI.Bytes() stores lengths in uint64
+const version = 2
+
+// ErrFormat data is not an index file, or not of the kind read
+var ErrFormat = errors.New("ctor: not an index file")
+
+// WriteFile writes fmi to index file
+func WriteFile(file string, fmi hfmi.FMI) error {
+	return ioutil.WriteFile(file, Marshal(fmi), 0644)
+}
+
+// ReadFile reads index file
+func ReadFile(file string) (hfmi.FMI, error) {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	fmi, err := Unmarshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return fmi, nil
+}
+
+// Marshal returns fmi in index file format
+func Marshal(fmi hfmi.FMI) []byte {
+	dict := fmi.Dictionary()
+
+	var buf bytes.Buffer
+	buf.Write(magic)
+	buf.WriteByte(version)
+	binary.Write(&buf, binary.LittleEndian, uint64(fmi.Len()))
+	binary.Write(&buf, binary.LittleEndian, uint16(len(dict)))
+	buf.Write(dict)
+	buf.Write(fmi.Bytes())
+
+	return buf.Bytes()
+}
+
+// Unmarshal returns FM-index of d in index file format, d is referenced by the index
+func Unmarshal(d []byte) (hfmi.FMI, error) {
+	hdr := len(magic) + 1 + 8 + 2
+	if len(d) < hdr || !bytes.Equal(d[:len(magic)], magic) {
+		return nil, ErrFormat
+	}
+	v := d[len(magic)]
+	if v != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrFormat, v)
+	}
+
+	cnt := binary.LittleEndian.Uint64(d[len(magic)+1:])
+	n := int(binary.LittleEndian.Uint16(d[hdr-2:]))
+	if len(d) < hdr+n {
+		return nil, ErrFormat
+	}
+
+	body := d[hdr+n:]
+	if err := hybrid.Validate(uint(cnt), d[hdr:hdr+n], body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+
+	return Build(uint(cnt), d[hdr:hdr+n], body), nil
+}
+
+// WriteIntsFile writes fmi of integer sequence to index file
+func WriteIntsFile(file string, fmi hfmi.IntFMI) error {
+	return ioutil.WriteFile(file, MarshalInts(fmi), 0644)
+}
+
+// ReadIntsFile reads index file of integer sequence
+func ReadIntsFile(file string) (hfmi.IntFMI, error) {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	fmi, err := UnmarshalInts(d)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return fmi, nil
+}
+
+// MarshalInts returns fmi of integer sequence in index file format
+func MarshalInts(fmi hfmi.IntFMI) []byte {
+	return append(append(append([]byte{}, intsMagic...), version), fmi.Bytes()...)
+}
+
+// UnmarshalInts returns FM-index of integer sequence of d in index file format
+func UnmarshalInts(d []byte) (hfmi.IntFMI, error) {
+	hdr := len(intsMagic) + 1
+	if len(d) < hdr || !bytes.Equal(d[:len(intsMagic)], intsMagic) {
+		return nil, ErrFormat
+	}
+	if v := d[len(intsMagic)]; v != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrFormat, v)
+	}
+
+	if err := ValidateInts(d[hdr:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+	return BuildInts(d[hdr:]), nil
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package ctor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	text := []byte("login ok\x01login failed\x01logout")
+	fmi := New(append([]byte{}, text...))
+	d := Marshal(fmi)
+
+	hdr := len(magic) + 1 + 8 + 2 + len(fmi.Dictionary())
+	body := fmi.Bytes()
+	n := binary.LittleEndian.Uint64(body)
+
+	tests := []struct {
+		name string
+		d    []byte
+		err  error
+	}{
+		{"v2", d, nil},
+		{"magic", append([]byte("FMI"), d[3:]...), ErrFormat},
+		{"version", append(append(append([]byte{}, d[:4]...), 3), d[5:]...), ErrFormat},
+		{"v1", append(append(append([]byte{}, d[:4]...), 1), d[5:]...), ErrFormat},
+		{"truncated", d[:hdr+12], ErrFormat},
+		{"header", d[:len(d)-len(body)+8+int(n)-1], ErrFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unmarshal(tt.d)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Unmarshal() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(got.Bytes(), body) || got.Len() != fmi.Len() || got.Count("login") != 2 {
+				t.Errorf("Unmarshal() differs from index")
+			}
+		})
+	}
+}
+
+// v0 returns d of FMI.Bytes() in version 0 layout, uint32 header length and number of chars
+func v0(d []byte) []byte {
+	b := make([]byte, 8, len(d)-8)
+	binary.LittleEndian.PutUint32(b, uint32(binary.LittleEndian.Uint64(d)-4))
+	binary.LittleEndian.PutUint32(b[4:], uint32(binary.LittleEndian.Uint64(d[8:])))
+	return append(b, d[16:]...)
+}
+
+func TestBuildV0(t *testing.T) {
+	fmi := New([]byte("login ok\x01login failed\x01logout"))
+	body, dict := fmi.Bytes(), fmi.Dictionary()
+	d := v0(body)
+
+	if err := Validate(fmi.Len(), dict, d); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	got := Build(fmi.Len(), dict, d)
+	if !bytes.Equal(got.Bytes(), body) || got.Count("login") != 2 || got.Count("ok") != 1 {
+		t.Errorf("Build() differs from index")
+	}
+	if err := Validate(fmi.Len(), dict, d[:len(d)-1]); err == nil {
+		t.Errorf("Validate() of truncated version 0 is nil")
+	}
+}
+
+func TestUnmarshalInts(t *testing.T) {
+	fmi := NewInts([]uint32{3, 1, 4, 1, 5, 9, 2, 6, 5, 3, 5})
+	d := MarshalInts(fmi)
+
+	tests := []struct {
+		name string
+		d    []byte
+		err  error
+	}{
+		{"ints", d, nil},
+		{"text index", Marshal(New([]byte("login ok"))), ErrFormat},
+		{"version", append(append(append([]byte{}, d[:4]...), 3), d[5:]...), ErrFormat},
+		{"truncated", d[:len(d)-1], ErrFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnmarshalInts(tt.d)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("UnmarshalInts() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.Equal(got.Bytes(), fmi.Bytes()) || got.Count([]uint32{5}) != 3 {
+				t.Errorf("UnmarshalInts() differs from index")
+			}
+		})
+	}
+
+	if _, err := Unmarshal(d); !errors.Is(err, ErrFormat) {
+		t.Errorf("Unmarshal() error = %v, want %v", err, ErrFormat)
+	}
+}
@@ -19,7 +19,89 @@
 
 package hfmi
 
-import "io"
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrSeparator separator doesn't exist in index
+	ErrSeparator = errors.New("hfmi: separator not found")
+	// ErrDocument document id is out of range
+	ErrDocument = errors.New("hfmi: document out of range")
+	// ErrRowSeparator rows are only iterated by separator byte 1
+	ErrRowSeparator = errors.New("hfmi: row separator must be byte 1")
+	// ErrFieldCount rows must have at least one field
+	ErrFieldCount = errors.New("hfmi: number of fields must be positive")
+)
+
+// RestoreOptions renders byte 0 (end of text) and byte 1 (separator) in restored text
+type RestoreOptions struct {
+	Zero []byte
+	One  []byte
+	// Workers decodes documents concurrently if more than 1
+	Workers int
+}
+
+// DefaultRestore renders separator as space, same as Restore
+var DefaultRestore = RestoreOptions{One: []byte{' '}}
+
+// RowIterator iterates rows of fields with bounded memory
+type RowIterator interface {
+	// Next advances to the next row, returns false at the end or on error
+	Next() bool
+
+	// Fields returns fields of the current row, valid until the next call of Next
+	Fields() [][]byte
+
+	// Row returns zero based row number of the current row, 0 before the first call of Next
+	Row() uint
+
+	// Seek resumes iteration from row, the next call of Next advances to row
+	Seek(row uint) bool
+
+	// Err returns error stops the iteration
+	Err() error
+}
+
+// MEM maximal exact match, query[Offset:Offset+Len] occurs in text at range in BWT (s, e]
+type MEM struct {
+	Offset uint
+	Len    uint
+	Range  []uint
+}
+
+// Encoding compression report of blocks of one encoding
+type Encoding struct {
+	Name        string `json:"name"`
+	Blocks      uint   `json:"blocks"`
+	HeaderBytes uint   `json:"header_bytes"`
+	BodyBytes   uint   `json:"body_bytes"`
+	Symbols     uint   `json:"symbols"` // total number of distinct symbols of all blocks
+}
+
+// Report compression report of index
+type Report struct {
+	Len         uint       `json:"len"`        // number of symbols, including the sentinel
+	BlockSize   uint       `json:"block_size"` // number of symbols per block
+	Blocks      uint       `json:"blocks"`
+	HeaderBytes uint       `json:"header_bytes"`
+	BodyBytes   uint       `json:"body_bytes"`
+	SuperBlocks uint       `json:"super_blocks"`
+	SuperBytes  uint       `json:"super_bytes"` // in memory absolute ranks of super blocks
+	Encodings   []Encoding `json:"encodings"`
+	// Entropy[i] number of blocks, whose entropy is in [i, i+1) bits per symbol
+	Entropy [8]uint `json:"entropy"`
+}
+
+// BitsPerSymbol returns compressed bits per input symbol
+func (r Report) BitsPerSymbol() float64 {
+	if r.Len == 0 {
+		return 0
+	}
+	return float64(8*(r.HeaderBytes+r.BodyBytes)) / float64(r.Len)
+}
 
 // Succinct defines Rank/Select succinct data structure
 type Succinct interface {
@@ -48,12 +130,31 @@ type FMI interface {
 	// Count the number of pattern occurrence
 	Count(string) uint
 
+	// Offset returns text offset of the char at p-th position of F, p is zero based offset
+	Offset(p uint) (uint, bool)
+
+	// Occurrences returns text offsets of pattern occurrence in ascending order
+	Occurrences(string) []uint
+
 	// Search search pattern, return range in BWT (s, e]
 	Search(string) ([]uint, bool)
 
+	// SearchMany search patterns in batch, return range in BWT (s, e] keyed by found pattern
+	SearchMany([]string) map[string][]uint
+
+	// MatchingStatistics returns, for each offset i of query, the length of the longest prefix of query[i:] occurs in text,
+	// separators never match
+	MatchingStatistics(string) []uint
+
+	// MEMs returns maximal exact matches of query, which are at least minLen long
+	MEMs(query string, minLen uint) []MEM
+
 	// Size return the size of header and body bit vector
 	Size() (int, int)
 
+	// Inspect returns compression report of blocks
+	Inspect() Report
+
 	// Len return original text len
 	Len() uint
 
@@ -66,9 +167,18 @@ type FMI interface {
 	// GetBound return (start, end] range bound
 	GetBound(byte) (uint, uint, bool)
 
-	//
+	// Restore writes the original text to writer, separators are written as space
 	Restore(io.Writer) bool
 
+	// RestoreTo writes the original text to writer, renders byte 0 and 1 with opts
+	RestoreTo(context.Context, io.Writer, RestoreOptions) error
+
+	// Documents returns the number of documents, which are separated by byte 1
+	Documents() uint
+
+	// RestoreDocument writes id-th document to writer, id is zero based
+	RestoreDocument(ctx context.Context, w io.Writer, id uint) error
+
 	// ForwardExtractToChar return []byte, position, ok, walking BWT forward from p, until found b
 	ForwardExtractToChar(uint, byte) ([]byte, uint, bool)
 
@@ -84,6 +194,48 @@ type FMI interface {
 
 	ExtractAllFields(sep byte, fc uint) ([][][]byte, bool)
 
+	// Rows returns iterator of rows, each row has fc fields separated by sep, sep must be byte 1
+	Rows(sep byte, fc uint) RowIterator
+
 	// ExtractRange, return []byte between from and to or 0/1 byte, which ever comes first
 	ExtractRange(from, to uint) ([]byte, bool)
 }
+
+// IntFMI FM-index of integer sequence, e.g. token ids, the index sorts prefixes as FMI does
+type IntFMI interface {
+	// Access returns symbol and its rank at p-th position, ok is false at the end of text
+	Access(p uint) (a uint32, r uint, ok bool)
+
+	// Select returns the position p of r-th ranked a, p is zero based offset
+	Select(a uint32, r uint) (p uint, ok bool)
+
+	// Rank returns the rank, r-th of symbol at the position p, p is zero based offset
+	Rank(a uint32, p uint) (r uint, ok bool)
+
+	// Locate returns the bucket symbol and its rank at p-th position, ok is false at the end of text
+	Locate(uint) (uint32, uint, bool)
+
+	// Count the number of pattern occurrence
+	Count([]uint32) uint
+
+	// Search search pattern, return range in BWT (s, e]
+	Search([]uint32) ([]uint, bool)
+
+	// GetBound return (start, end] range bound
+	GetBound(uint32) (uint, uint, bool)
+
+	// Offset returns text offset of the symbol at p-th position of F, p is zero based offset
+	Offset(p uint) (uint, bool)
+
+	// Occurrences returns text offsets of pattern occurrence in ascending order
+	Occurrences([]uint32) []uint
+
+	// Extract returns n symbols of text from offset, up to the end of text
+	Extract(offset, n uint) ([]uint32, bool)
+
+	// Len return the number of positions in BWT, including the end of text
+	Len() uint
+
+	// Bytes returns serialized index, restored by ctor.BuildInts
+	Bytes() []byte
+}
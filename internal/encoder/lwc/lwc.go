@@ -37,7 +37,8 @@ func init() {
 }
 
 func Expand(src, chars []byte) []byte {
-	dst, sz := internal.GetFreeSegment(), len(src)
+	// note: expanded block is kept by the index, it must not borrow a segment of the cache
+	dst, sz := make([]byte, internal.SZ), len(src)
 	if len(chars) < 3 {
 		_ = dst[internal.SZ-1]
 		for i := 0; i < sz; i++ {
@@ -84,7 +85,8 @@ func (l *lwc) Rank(a byte, p uint, bv []byte) uint {
 }
 
 func Encode(dst, src []byte, mfc byte, chars []byte, hist []uint16) uint {
-	convert := internal.GetFreeSegment()
+	// note: scratch of the calling goroutine, blocks are encoded concurrently
+	var convert [256]byte
 	for i, c := range chars {
 		convert[c] = byte(i)
 	}
@@ -36,7 +36,6 @@ func TestMain(m *testing.M) {
 	for i := range chars {
 		chars[i] = byte(255 - i)
 	}
-	internal.InitSegmentCache(1)
 	os.Exit(m.Run())
 }
 
@@ -77,6 +76,24 @@ func TestEncodeExpandPartial(t *testing.T) {
 	}
 }
 
+// TestExpandOwned expanded blocks are kept by the index, they must not share a buffer
+func TestExpandOwned(t *testing.T) {
+	wantA, wantB := []byte("tobeornottobethatisthequestion"), []byte("abracadabra, abracadabra")
+
+	expand := func(data []byte) []byte {
+		chars, hist, mfc, _ := internal.CalcBlockHistogram(data)
+		bv := make([]byte, internal.SZ)
+		s := Encode(bv, data, mfc, chars, hist)
+		return Expand(bv[:s], chars)
+	}
+
+	a := expand(wantA)
+	b := expand(wantB)
+	if !reflect.DeepEqual(a[:len(wantA)], wantA) || !reflect.DeepEqual(b[:len(wantB)], wantB) {
+		t.Errorf("Expand() = %q, %q, want %q, %q", a[:len(wantA)], b[:len(wantB)], wantA, wantB)
+	}
+}
+
 func TestAccess(t *testing.T) {
 	type args struct {
 		bv []byte
@@ -154,20 +171,20 @@ func BenchmarkRank(b *testing.B) {
 }
 
 func prepare(freq int, data []byte) ([]byte, []uint16) {
-	chars, hist := make([]byte, freq), make([]uint16, freq)
+	cs, hist := make([]byte, freq), make([]uint16, freq)
 	for i := 0; i < freq; i++ {
-		chars[i] = chars[i]
+		cs[i] = chars[i]
 	}
 
 	for len(data) >= freq {
 		for i := 0; i < freq; i++ {
-			data[i] = chars[i]
+			data[i] = cs[i]
 		}
 		data = data[freq:]
 	}
 	for i := 0; i < len(data); i++ {
-		data[i] = chars[i]
+		data[i] = cs[i]
 	}
 
-	return chars, hist
+	return cs, hist
 }
@@ -70,10 +70,12 @@ func (*runlen) Access(p uint, bv []byte) (byte, uint) {
 		ptr += uint(bv[i])
 	}
 
+	// ranks = current pos (p) - offset of current run (last) + previous rank of b
+	// note: read rank before ranks is put back, Access runs concurrently
+	r := p - ptr + ranks[b] + 1
 	pool.Put(ranks)
 
-	// ranks = current pos (p) - offset of current run (last) + previous rank of b
-	return b, p - ptr + ranks[b] + 1
+	return b, r
 }
 
 func (*runlen) Rank(a byte, p uint, bv []byte) uint {
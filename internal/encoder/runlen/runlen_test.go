@@ -20,6 +20,8 @@
 package runlen
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/rleiwang/hfmi/internal"
@@ -55,6 +57,39 @@ func TestAccess(t *testing.T) {
 	}
 }
 
+// TestAccessConcurrent Access is called by concurrent goroutines, e.g. RestoreTo of workers
+func TestAccessConcurrent(t *testing.T) {
+	data := []byte("aaaabbbbbbbaaaccccccccaaaaaaadddddaaaaaaaaaabbbbbbeeeeaaaa")
+	chars, hist, mfc, runs := internal.CalcBlockHistogram(data)
+	bv := make([]byte, CompSZ(chars, hist, runs))
+	Encode(bv, data, mfc, chars, hist)
+	r := &runlen{}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < cap(errs); g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 1000; n++ {
+				ranks := [256]uint{}
+				for i, c := range data {
+					ranks[c]++
+					if gotc, gotr := r.Access(uint(i), bv); gotc != c || gotr != ranks[c] {
+						errs <- fmt.Errorf("runlen.Access(%v) = %v, %v, want %v, %v", i, gotc, gotr, c, ranks[c])
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
 func TestRank(t *testing.T) {
 	type args struct {
 		bv []byte
@@ -36,11 +36,15 @@ import (
 // ├─┼────┤
 // │3│ 14 │
 // └─┴────┘
+// offsets are relative to the block, a byte suffices for blocks of internal.SZ bytes, whatever the text length
 
 type sparse struct {
 	mfc byte
 }
 
+// fails to compile if block offsets overflow a byte
+var _ [256 - internal.SZ]struct{}
+
 var (
 	zeros [256]uint
 
@@ -64,8 +68,10 @@ func (s *sparse) Access(p uint, bv []byte) (byte, uint) {
 		b = bv[i]
 		i++
 		if uint(bv[i]) == p {
+			// note: read rank before ranks is put back, Access runs concurrently
+			r := ranks[b] + 1
 			pool.Put(ranks)
-			return b, ranks[b] + 1
+			return b, r
 		} else if uint(bv[i]) > p {
 			// offset is over, T[p] is the frequent character
 			break
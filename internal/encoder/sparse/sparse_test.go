@@ -21,6 +21,7 @@ package sparse
 
 import (
     "fmt"
+    "sync"
     "testing"
 
     "github.com/rleiwang/hfmi/internal"
@@ -84,6 +85,39 @@ func TestRank(t *testing.T) {
     }
 }
 
+// TestAccessConcurrent Access is called by concurrent goroutines, e.g. RestoreTo of workers
+func TestAccessConcurrent(t *testing.T) {
+    bv := []byte("aaaabaaaaaaacaaaaaaaaaabaaaaaaaaaaaaadaaaaaaaaaaaaaaaaaaaaaaeaaa")
+    chars, hist, mfc, _ := internal.CalcBlockHistogram(bv)
+    dst := make([]byte, 256)
+    r := Encode(dst, bv, mfc, chars, hist)
+    s := &sparse{mfc}
+
+    var wg sync.WaitGroup
+    errs := make(chan error, 8)
+    for g := 0; g < cap(errs); g++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for n := 0; n < 1000; n++ {
+                ranks := [256]uint{}
+                for i, c := range bv {
+                    ranks[c]++
+                    if gotc, gotr := s.Access(uint(i), dst[:r]); gotc != c || gotr != ranks[c] {
+                        errs <- fmt.Errorf("sparse.Access(%v) = %v, %v, want %v, %v", i, gotc, gotr, c, ranks[c])
+                        return
+                    }
+                }
+            }
+        }()
+    }
+    wg.Wait()
+    close(errs)
+    for err := range errs {
+        t.Error(err)
+    }
+}
+
 func BenchmarkAccess(b *testing.B) {
     type args struct {
         bv []byte
@@ -19,18 +19,10 @@
 
 package internal
 
-import "sync/atomic"
-
 const (
 	SZ = 256 // block must be N * 64
 )
 
-var (
-	csz      uint64
-	segments []byte
-	counter  uint64
-)
-
 type SDS interface {
 	Access(uint, []byte) (byte, uint)
 	Rank(byte, uint, []byte) uint
@@ -38,22 +30,6 @@ type SDS interface {
 
 type Encoder func([]byte, []byte, byte, []byte, []uint16) uint
 
-func InitSegmentCache(sz uint64) {
-	segments = make([]byte, SZ*sz)
-	csz = sz
-}
-
-func GetFreeSegment() []byte {
-	n := (atomic.AddUint64(&counter, 1) % csz) * SZ
-	bv := segments[n : n+SZ]
-	bv[0] = 0
-	for i := 1; i <= 128; i <<= 1 {
-		copy(bv[i:], bv[:i])
-	}
-
-	return bv
-}
-
 func CalcBlockHistogram(data []byte) ([]byte, []uint16, byte, uint) {
 	chars, hist, prev, runs := [256]byte{}, [256]uint16{}, data[0], uint(1)
 	hist[prev]++
@@ -31,8 +31,11 @@ import (
 	spenc "github.com/rleiwang/hfmi/internal/encoder/sparse"
 )
 
-// New build FMI index from text t
+// New build FMI index from text t, t must pass ValidateText
 func New(t []byte) hfmi.FMI {
+	if err := ValidateText(t); err != nil {
+		panic(err)
+	}
 	// eob, end of bwt
 	_, bwt, aux := sa.BWT(t)
 	// aux.Dict -> reverse index, fidx -> forward index
@@ -41,16 +44,36 @@ func New(t []byte) hfmi.FMI {
 	return buildFMI(bwt, &dictionary{fidx: fidx, ridx: aux.Dict})
 }
 
+// Build restores FMI index from d serialized by Bytes(), d must pass Validate
 func Build(cnt uint, ridx, d []byte) hfmi.FMI {
-	offset := binary.LittleEndian.Uint32(d[:4]) + 4
+	d = upgrade(d)
+	offset := binary.LittleEndian.Uint64(d[:lensz]) + lensz
 	return restoreHeader(&hybrid{
 		cnt:  cnt,
-		hdr:  d[4:offset],
+		hdr:  d[lensz:offset],
 		bv:   d[offset:],
 		dict: &dictionary{fidx: newForwardIndex(ridx), ridx: ridx},
 	})
 }
 
+// upgrade copies d serialized by Bytes() of version 0, which stores the header length and the number of chars
+// in uint32, to the uint64 layout. Version 0 is told apart by its number of chars, never 0, in the high half of
+// the uint64 header length, so the length is out of range. d of the uint64 layout is returned as is.
+func upgrade(d []byte) []byte {
+	if len(d) < lensz || binary.LittleEndian.Uint64(d) <= uint64(len(d)-lensz) {
+		return d
+	}
+	n := uint64(binary.LittleEndian.Uint32(d))
+	if n < 4 || n > uint64(len(d)-4) || binary.LittleEndian.Uint32(d[4:]) == 0 {
+		return d
+	}
+
+	b := make([]byte, lensz+cntsz, uint64(len(d))+lensz+cntsz-8)
+	binary.LittleEndian.PutUint64(b, n+cntsz-4)
+	binary.LittleEndian.PutUint64(b[lensz:], uint64(binary.LittleEndian.Uint32(d[4:])))
+	return append(b, d[8:]...)
+}
+
 func newForwardIndex(ridx []byte) []byte {
 	fidx := make([]byte, 256, 256)
 	fidx[0] = 255
@@ -72,13 +95,14 @@ func buildFMI(bwt []byte, dict *dictionary) hfmi.FMI {
 
 	blocks := split(bwt, internal.SZ)
 
-	// max header sz: (3 + 256 * 2) * len(blocks), max bv sz: len(bwt)
-	buf := make([]byte, 2*internal.SZ*len(blocks)+4)
-	header, bv := buf[:4+len(buf)/2], buf[4+len(buf)/2:]
-	hdrBeg, bvBeg, count := uint(4), uint(0), uint32(0)
+	// max header sz: (3 + 256 * 2) per block, but typically a few bytes,
+	// max bv sz: len(bwt), but runlen and sparse of up to 16 bytes may encode the last block shorter than 16
+	var blkHdr [3 + 2*αsz]byte
+	header, bv := make([]byte, cntsz, cntsz+8*len(blocks)), make([]byte, len(bwt)+16)
+	bvBeg, count := uint(0), uint64(0)
 	for _, b := range blocks {
 		chars, hist, mfc, runs := internal.CalcBlockHistogram(b)
-		count += uint32(len(chars))
+		count += uint64(len(chars))
 		e, s := single, uint(0)
 		var enc internal.Encoder
 		if runs > 1 {
@@ -86,14 +110,14 @@ func buildFMI(bwt []byte, dict *dictionary) hfmi.FMI {
 			s = enc(bv[bvBeg:], b, mfc, chars, hist)
 			bvBeg += s
 		}
-		hsz := encodeHeader(chars, hist, e, s, header[hdrBeg:])
-		hdrBeg += hsz
+		hsz := encodeHeader(chars, hist, e, s, blkHdr[:])
+		header = append(header, blkHdr[:hsz]...)
 	}
 
-	binary.LittleEndian.PutUint32(buf, count)
+	binary.LittleEndian.PutUint64(header, count)
 	return restoreHeader(&hybrid{
 		cnt:  uint(len(bwt)),
-		hdr:  header[:hdrBeg],
+		hdr:  header,
 		bv:   bv[:bvBeg],
 		dict: dict,
 	})
@@ -19,7 +19,13 @@
 
 package hybrid
 
-import "github.com/rleiwang/hfmi/internal"
+import (
+	"sort"
+	"sync"
+
+	"github.com/rleiwang/hfmi/internal"
+	"github.com/rleiwang/hfmi/internal/lcp"
+)
 
 // encoding type
 type edt byte
@@ -40,6 +46,18 @@ const (
 	αsz = 256
 )
 
+const (
+	cntsz = 8 // uint64 number of chars of all blocks, at the beginning of header
+	lensz = 8 // uint64 length of header, at the beginning of Bytes()
+)
+
+const (
+	minBatch  = 64   // min number of patterns per goroutine in batch search
+	saRate    = 32   // sample rate of text offsets
+	ctxRate   = 4096 // check context cancellation every ctxRate chars
+	msRestart = 16   // matching statistics shorter than msRestart are restarted rather than widened
+)
+
 type pair struct {
 	v uint
 	b byte
@@ -66,10 +84,48 @@ type dictionary struct {
 	ridx []byte // reverse index: ith char -> byte
 }
 
+// samples sampled text offsets, built on demand
+type samples struct {
+	once sync.Once
+	rows []uint // sampled positions in BWT, ascending
+	offs []uint // text offsets of sampled positions
+	seps []uint // text offsets of separators
+}
+
+// lazyLCP lcp array, built on the first matching statistics
+type lazyLCP struct {
+	once sync.Once
+	*lcp.LCP
+}
+
 type hybrid struct {
 	cnt  uint        // total count
 	hdr  []byte      // compressed header
 	bv   []byte      // compressed bit vector
 	dict *dictionary // dictionary
 	m    meta        //
+	sa   samples     // sampled text offsets
+	lcp  lazyLCP     // lcp array for matching statistics
+}
+
+func (s *samples) Len() int {
+	return len(s.rows)
+}
+
+func (s *samples) Less(i, j int) bool {
+	return s.rows[i] < s.rows[j]
+}
+
+func (s *samples) Swap(i, j int) {
+	s.rows[i], s.rows[j] = s.rows[j], s.rows[i]
+	s.offs[i], s.offs[j] = s.offs[j], s.offs[i]
+}
+
+// find returns text offset of position p in BWT, false if p is not sampled
+func (s *samples) find(p uint) (uint, bool) {
+	i := sort.Search(len(s.rows), func(i int) bool { return s.rows[i] >= p })
+	if i < len(s.rows) && s.rows[i] == p {
+		return s.offs[i], true
+	}
+	return 0, false
 }
@@ -0,0 +1,255 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package hybrid
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/internal/testutil"
+)
+
+// maxFuzzLen keeps the naive reference fast
+const maxFuzzLen = 512
+
+// reference naive FM-index of prefix sorted text
+type reference struct {
+	text []byte
+	rows []int  // length of prefix of each row
+	l    []byte // char follows prefix of each row, 0 at the end of text
+}
+
+func newReference(text []byte) *reference {
+	rows := make([]int, len(text)+1)
+	for i := range rows {
+		rows[i] = i
+	}
+	sort.Slice(rows, func(x, y int) bool { return prefixLess(text, rows[x], rows[y]) })
+
+	l := make([]byte, len(rows))
+	for p, i := range rows {
+		if i < len(text) {
+			l[p] = text[i]
+		}
+	}
+	return &reference{text, rows, l}
+}
+
+// prefixLess compares text[:i] and text[:j] backward up to a separator or the beginning,
+// the beginning sorts first, separators sort by text offset
+func prefixLess(text []byte, i, j int) bool {
+	for {
+		if i == 0 || j == 0 {
+			return i == 0 && j != 0
+		}
+		a, b := text[i-1], text[j-1]
+		if a == 1 && b == 1 {
+			return i < j
+		}
+		if a != b {
+			return a < b
+		}
+		i, j = i-1, j-1
+	}
+}
+
+// occurrences returns offsets of pattern in text, overlapped ones included
+func (r *reference) occurrences(pat []byte) []uint {
+	var offs []uint
+	for i := 0; i+len(pat) <= len(r.text); i++ {
+		if bytes.Equal(r.text[i:i+len(pat)], pat) {
+			offs = append(offs, uint(i))
+		}
+	}
+	return offs
+}
+
+func seeds() [][]byte {
+	distinct := make([]byte, 0, 512)
+	for c := 2; c < 256; c++ {
+		distinct = append(distinct, byte(c))
+	}
+	distinct = append(distinct, 1)
+	for c := 255; c > 1; c-- {
+		distinct = append(distinct, byte(c))
+	}
+
+	return [][]byte{
+		[]byte("tobeornottobethatisthequestion"),
+		[]byte("a"),
+		[]byte("zz\x01a\x01zz"),
+		[]byte("\xff\x01\xff\xff\x01\xff"),
+		[]byte("\x00\x01\xff\x00\x01\x01\xff"),
+		[]byte("a\x01\x01b"),
+		[]byte("a\x00b"),
+		[]byte("ab\x01cd\x00ef\x01gh\x00ij"),
+		[]byte("\x01ab"),
+		[]byte("tobe\x01or\x01"),
+		{},
+		bytes.Repeat([]byte("a"), 1000),
+		bytes.Repeat([]byte{255}, 600),
+		bytes.Repeat([]byte("ab\x01"), 300),
+		distinct,
+		testutil.Words(7, 200, 1),
+	}
+}
+
+func FuzzNew(f *testing.F) {
+	for i, s := range seeds() {
+		f.Add(s, uint16(i*7), uint16(i))
+	}
+	f.Fuzz(checkNew)
+}
+
+// checkNew checks New panics on text ValidateText rejects, otherwise checks index of text against the naive
+// reference, pattern is text[from:from+n%16+1]
+func checkNew(t *testing.T, text []byte, from, n uint16) {
+	if len(text) > maxFuzzLen {
+		text = text[:maxFuzzLen]
+	}
+	if err := ValidateText(text); err != nil {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("New(%q) doesn't panic, ValidateText() error = %v", text, err)
+			}
+		}()
+		New(append([]byte{}, text...))
+		return
+	}
+	ref := newReference(text)
+	fmi := New(append([]byte{}, text...))
+
+	if fmi.Len() != uint(len(ref.l)) {
+		t.Fatalf("Len() = %v, want %v", fmi.Len(), len(ref.l))
+	}
+
+	// Access, Rank, Select and Offset of every position
+	ranks := [256]uint{}
+	for p, c := range ref.l {
+		ranks[c]++
+		a, r, ok := fmi.Access(uint(p))
+		if !ok || a != c || r != ranks[c] {
+			t.Fatalf("Access(%d) = %d, %d, want %d, %d", p, a, r, c, ranks[c])
+		}
+		if r, _ := fmi.Rank(c, uint(p)); r != ranks[c] {
+			t.Fatalf("Rank(%d, %d) = %d, want %d", c, p, r, ranks[c])
+		}
+		if q, ok := fmi.Select(c, ranks[c]); !ok || q != uint(p) {
+			t.Fatalf("Select(%d, %d) = %d, want %d", c, ranks[c], q, p)
+		}
+		if i := ref.rows[p]; i > 0 {
+			if o, ok := fmi.Offset(uint(p)); !ok || o != uint(i-1) {
+				t.Fatalf("Offset(%d) = %d, want %d", p, o, i-1)
+			}
+		}
+	}
+
+	// ExtractRange up to the next separator or the end of text, of every 8th position
+	for p := 0; p < len(ref.rows); p += 8 {
+		i := ref.rows[p]
+		want := ref.text[i:]
+		if j := bytes.IndexByte(want, 1); j >= 0 {
+			want = want[:j]
+		}
+		if got, ok := fmi.ExtractRange(uint(p), fmi.Len()); !ok || !bytes.Equal(got, want) {
+			t.Fatalf("ExtractRange(%d) = %q, want %q", p, got, want)
+		}
+	}
+
+	// Count, Search and Occurrences of pattern in a document
+	s := int(from) % len(text)
+	pat := text[s:]
+	if j := bytes.IndexByte(pat, 1); j >= 0 {
+		pat = pat[:j]
+	}
+	if int(n%16)+1 < len(pat) {
+		pat = pat[:n%16+1]
+	}
+	if len(pat) > 0 {
+		want := ref.occurrences(pat)
+		if got := fmi.Count(string(pat)); got != uint(len(want)) {
+			t.Fatalf("Count(%q) = %v, want %v", pat, got, len(want))
+		}
+		if rng, ok := fmi.Search(string(pat)); !ok || rng[1]-rng[0] != uint(len(want)) {
+			t.Fatalf("Search(%q) = %v, want %v occurrences", pat, rng, len(want))
+		}
+		if got := fmi.Occurrences(string(pat)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Occurrences(%q) = %v, want %v", pat, got, want)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := fmi.RestoreTo(context.Background(), &b, hfmi.RestoreOptions{One: []byte{1}}); err != nil || !bytes.Equal(b.Bytes(), text) {
+		t.Fatalf("RestoreTo() = %q, %v, want %q", b.Bytes(), err, text)
+	}
+
+	// Build(Bytes()) round trip
+	d := fmi.Bytes()
+	if err := Validate(fmi.Len(), fmi.Dictionary(), d); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if got := Build(fmi.Len(), fmi.Dictionary(), d); !bytes.Equal(got.Bytes(), d) || got.Count(string(text[:1])) != fmi.Count(string(text[:1])) {
+		t.Fatalf("Build() differs from New()")
+	}
+}
+
+func FuzzBuild(f *testing.F) {
+	for _, s := range seeds() {
+		if ValidateText(s) != nil {
+			continue
+		}
+		fmi := New(append([]byte{}, s...))
+		d := fmi.Bytes()
+		f.Add(uint64(fmi.Len()), fmi.Dictionary(), d)
+
+		// corrupt header length, chars and body
+		for _, i := range []int{0, lensz + cntsz, lensz + cntsz + 2, len(d) - 1} {
+			c := append([]byte{}, d...)
+			c[i] ^= 0x5A
+			f.Add(uint64(fmi.Len()), fmi.Dictionary(), c)
+		}
+		f.Add(uint64(fmi.Len()+1), fmi.Dictionary(), d)
+		f.Add(uint64(fmi.Len()), fmi.Dictionary(), d[:len(d)-1])
+	}
+	f.Fuzz(func(t *testing.T, cnt uint64, ridx, d []byte) {
+		if Validate(uint(cnt), ridx, d) != nil {
+			return
+		}
+
+		// valid bytes build a consistent index
+		fmi := Build(uint(cnt), ridx, d)
+		if !bytes.Equal(fmi.Bytes(), upgrade(d)) {
+			t.Fatalf("Bytes() differs from Build()")
+		}
+		for p := uint(0); p < fmi.Len(); p++ {
+			a, r, _ := fmi.Access(p)
+			if rank, _ := fmi.Rank(a, p); rank != r {
+				t.Fatalf("Access(%d) = %d, %d, Rank() = %d", p, a, r, rank)
+			}
+		}
+	})
+}
@@ -112,43 +112,56 @@ func encodeHeader(chars []byte, hist []uint16, e edt, bsz uint, header []byte) u
 	return i
 }
 
+// blockHeader decodes header of block starts at hdr[i], returns encoding type, number of chars,
+// size of bv, and index of the last byte before chars and freqs
+func blockHeader(hdr []byte, i int) (edt, uint, uint, int) {
+	cnt, t := uint(1), single
+	if hdr[i]&msb != 0 {
+		// msb is set, if single, 1000_0000
+		if hdr[i] > msb {
+			// msb is set, # of chars <= 2^5, 1010_0100
+			t = edt(0x03 & (hdr[i] >> htp))
+			cnt = uint(hdr[i] & mask)
+			if cnt == 0 {
+				cnt = 32
+			}
+		}
+	} else {
+		// 0100_0000, extract bit 5 and 6 to get edt
+		t = edt(hdr[i] >> htp)
+		i++
+		// encoding scheme ensures # of chars in each block always be less than 256
+		cnt = uint(hdr[i])
+	}
+
+	sz := uint(0)
+	if cnt > 1 {
+		i++
+		// size of bv == 0 iff it is 256
+		if sz = uint(hdr[i]); sz == 0 {
+			sz = 256
+		}
+	}
+
+	return t, cnt, sz, i
+}
+
 func restoreHeader(h *hybrid) *hybrid {
 	end, rank := uint(0), [256]uint{}
 
-	count := binary.LittleEndian.Uint32(h.hdr[:4])
+	count := binary.LittleEndian.Uint64(h.hdr[:cntsz])
 	h.m.char, h.m.hist = make([]byte, count), make([]uint16, count)
 	h.m.bsz, h.m.bsds = make([]uint16, 0, 1+h.cnt/internal.SZ), make([]internal.SDS, 0, 1+h.cnt/internal.SZ)
 	h.m.bbv = make([][]byte, 0, 1+h.cnt/internal.SZ)
 
-	for i, j := 4, uint(0); i < len(h.hdr); i++ {
-		cnt, t := uint(1), single
-		if h.hdr[i]&msb != 0 {
-			// msb is set, if single, 1000_0000
-			if h.hdr[i] > msb {
-				// msb is set, # of chars <= 2^5, 1010_0100
-				t = edt(0x03 & (h.hdr[i] >> htp))
-				cnt = uint(h.hdr[i] & mask)
-				if cnt == 0 {
-					cnt = 32
-				}
-			}
-		} else {
-			// 0100_0000, extract bit 5 and 6 to get edt
-			t = edt(h.hdr[i] >> htp)
-			i++
-			// encoding scheme ensures # of chars in each block always be less than 256
-			cnt = uint(h.hdr[i])
-		}
+	for i, j := cntsz, uint(0); i < len(h.hdr); i++ {
+		t, cnt, sz, k := blockHeader(h.hdr, i)
+		i = k
 
 		var bv []byte
 		if cnt > 1 {
-			beg := end
-			i++
-			end += uint(h.hdr[i])
-			if end == beg {
-				end += 256
-			}
-			bv = h.bv[beg:end]
+			bv = h.bv[end : end+sz]
+			end += sz
 		}
 
 		next := j + cnt
@@ -190,7 +203,8 @@ func restoreHeader(h *hybrid) *hybrid {
 	}
 
 	// count sentinel, the end of block
-	offset, idx := rank[0], byte(1)
+	// note: idx reaches 256 if all chars are present
+	offset, idx := rank[0], 1
 	h.m.eob = make([]pair, 256, 256)
 	h.m.ioe = makeAndInitArray(256, ^byte(0))
 	h.m.eob[0].v = offset
@@ -203,7 +217,7 @@ func restoreHeader(h *hybrid) *hybrid {
 		offset += c
 		h.m.eob[idx].v = offset
 		h.m.eob[idx].b = byte(i + 1)
-		h.m.ioe[i+1] = idx
+		h.m.ioe[i+1] = byte(idx)
 		idx++
 	}
 	h.m.eob = h.m.eob[:idx]
@@ -219,7 +233,8 @@ func (m *meta) getBlockRange(b byte) (uint, uint, bool) {
 		return 0, m.eob[0].v - 1, true
 	}
 	i := m.ioe[b]
-	if i == ^byte(0) {
+	// note: 255 marks absent char, unless all 256 chars are present
+	if i == ^byte(0) && len(m.eob) < αsz {
 		return 0, 0, false
 	}
 	return m.eob[i-1].v - 1, m.eob[i].v - 1, true
@@ -20,13 +20,19 @@
 package hybrid
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/binary"
 	"io"
 	"os"
+	"runtime"
 	"sort"
+	"sync"
 
+	"github.com/rleiwang/hfmi"
 	"github.com/rleiwang/hfmi/internal"
+	"github.com/rleiwang/hfmi/internal/lcp"
 )
 
 func (h *hybrid) Access(p uint) (a byte, r uint, ok bool) {
@@ -53,8 +59,41 @@ func (h *hybrid) Locate(p uint) (byte, uint, bool) {
 }
 
 func (h *hybrid) Select(a byte, r uint) (p uint, ok bool) {
-	// leave it blank
-	return 0, false
+	b := h.dict.fidx[a]
+	if r == 0 || (b == 255 && a != 255) {
+		return 0, false
+	}
+
+	// locate the super block, the first one whose ranks of b reaches r
+	i := sort.Search(len(h.m.super), func(i int) bool { return h.m.super[i].rank[b] >= r })
+	// blk -> block offset, rank -> ranks of b before blk, j -> offset of blk in char and hist
+	blk, rank, j := uint(0), uint(0), uint(0)
+	if i > 0 {
+		blk, rank, j = uint(i)*sbsz, h.m.super[i-1].rank[b], h.m.super[i-1].offset
+	}
+
+	// locate the block in the super block
+	for ; blk < uint(len(h.m.bsz)); blk++ {
+		n, e := uint(0), j+uint(h.m.bsz[blk])
+		for k := j; k < e; k++ {
+			if h.m.char[k] == b {
+				n = uint(h.m.hist[k])
+				break
+			}
+		}
+		if rank+n >= r {
+			break
+		}
+		rank, j = rank+n, e
+	}
+	if blk == uint(len(h.m.bsz)) {
+		return 0, false
+	}
+
+	// rank in block is monotonic, the first position whose rank reaches r
+	bsds, bv := h.m.bsds[blk], h.m.bbv[blk]
+	off := sort.Search(internal.SZ, func(q int) bool { return bsds.Rank(b, uint(q), bv)+rank >= r })
+	return blk*internal.SZ + uint(off), true
 }
 
 func (h *hybrid) Rank(a byte, p uint) (r uint, ok bool) {
@@ -68,8 +107,8 @@ func (h *hybrid) Dictionary() []byte {
 }
 
 func (h *hybrid) Bytes() []byte {
-	b := make([]byte, 4, 4)
-	binary.LittleEndian.PutUint32(b, uint32(len(h.hdr)))
+	b := make([]byte, lensz, lensz+len(h.hdr)+len(h.bv))
+	binary.LittleEndian.PutUint64(b, uint64(len(h.hdr)))
 
 	b = append(b, h.hdr...)
 	return append(b, h.bv...)
@@ -103,14 +142,9 @@ func (h *hybrid) Search(p string) ([]uint, bool) {
 		if s == e {
 			break
 		}
-		offset, _, ok := h.m.getBlockRange(b)
-		if !ok {
+		if s, e, ok = h.extend(b, s, e); !ok {
 			return nil, ok
 		}
-		s = offset + blockRank(b, s/internal.SZ, h.m.super, h.m.bsz, h.m.char, h.m.hist) +
-			h.m.bsds[s/internal.SZ].Rank(b, s%internal.SZ, h.m.bbv[s/internal.SZ])
-		e = offset + blockRank(b, e/internal.SZ, h.m.super, h.m.bsz, h.m.char, h.m.hist) +
-			h.m.bsds[e/internal.SZ].Rank(b, e%internal.SZ, h.m.bbv[e/internal.SZ])
 	}
 
 	if e > s {
@@ -120,6 +154,236 @@ func (h *hybrid) Search(p string) ([]uint, bool) {
 	return nil, false
 }
 
+func (h *hybrid) SearchMany(patterns []string) map[string][]uint {
+	// sort patterns, so that patterns share the same prefix are adjacent
+	pats := make([]string, 0, len(patterns))
+	seen := make(map[string]struct{}, len(patterns))
+	for _, p := range patterns {
+		if _, ok := seen[p]; !ok && len(p) > 0 {
+			seen[p] = struct{}{}
+			pats = append(pats, p)
+		}
+	}
+	sort.Strings(pats)
+
+	// split sorted patterns into chunks, one goroutine per chunk
+	rngs := make([][]uint, len(pats))
+	n := (len(pats) + runtime.GOMAXPROCS(0) - 1) / runtime.GOMAXPROCS(0)
+	if n < minBatch {
+		n = minBatch
+	}
+
+	var wg sync.WaitGroup
+	for s := 0; s < len(pats); s += n {
+		e := s + n
+		if e > len(pats) {
+			e = len(pats)
+		}
+		wg.Add(1)
+		go func(pats []string, rngs [][]uint) {
+			defer wg.Done()
+			h.searchSorted(pats, rngs)
+		}(pats[s:e], rngs[s:e])
+	}
+	wg.Wait()
+
+	ret := make(map[string][]uint, len(pats))
+	for i, rng := range rngs {
+		if rng != nil {
+			ret[pats[i]] = rng
+		}
+	}
+
+	return ret
+}
+
+// searchSorted searches sorted patterns, ranges of the common prefix with previous pattern are reused
+func (h *hybrid) searchSorted(pats []string, rngs [][]uint) {
+	// stack[i] is range (s, e] of prefix pat[:i+1]
+	var prev []byte
+	stack := make([][2]uint, 0, 64)
+	for i, p := range pats {
+		pat := []byte(p)
+		for j, b := range pat {
+			pat[j] = h.dict.fidx[b]
+		}
+
+		d := 0
+		for d < len(stack) && d < len(pat) && pat[d] == prev[d] {
+			d++
+		}
+		stack = stack[:d]
+
+		for _, b := range pat[d:] {
+			var s, e uint
+			var ok bool
+			if len(stack) == 0 {
+				s, e, ok = h.m.getBlockRange(b)
+			} else {
+				s, e, ok = h.extend(b, stack[len(stack)-1][0], stack[len(stack)-1][1])
+			}
+			if !ok || s == e {
+				break
+			}
+			stack = append(stack, [2]uint{s, e})
+		}
+
+		if len(stack) == len(pat) {
+			rngs[i] = []uint{stack[len(stack)-1][0], stack[len(stack)-1][1]}
+		}
+		prev = pat
+	}
+}
+
+func (h *hybrid) MatchingStatistics(q string) []uint {
+	ms, _ := h.matchingStatistics([]byte(q))
+	return ms
+}
+
+func (h *hybrid) MEMs(q string, minLen uint) []hfmi.MEM {
+	ms, rngs := h.matchingStatistics([]byte(q))
+
+	var mems []hfmi.MEM
+	for i, l := range ms {
+		// right maximal by ms[i], left maximal iff q[i-1:i+l] doesn't occur
+		if l == 0 || l < minLen || (i > 0 && ms[i-1] > l) {
+			continue
+		}
+		mems = append(mems, hfmi.MEM{Offset: uint(i), Len: l, Range: rngs[i]})
+	}
+
+	return mems
+}
+
+// matchingStatistics returns length and range (s, e] of the longest prefix of q[i:] occurs in text
+// note: forward search extends to the right only, on failure at q[j], q[i] is dropped by widening
+// (s, e] to the lcp interval of q[i+1:j), i.e. its parent in suffix tree of the reversed text,
+// hence every char of q is appended once, short matches are restarted instead.
+// separators (byte 0 and 1) never match, like lcp, rows are not sorted across them.
+func (h *hybrid) matchingStatistics(q []byte) ([]uint, [][]uint) {
+	pat := make([]byte, len(q))
+	for i, b := range q {
+		pat[i] = h.dict.fidx[b]
+	}
+
+	ms, rngs := make([]uint, len(pat)), make([][]uint, len(pat))
+	// current match is pat[i:j) in range (s, e]
+	s, e, j := uint(0), uint(0), 0
+	for i := range pat {
+		if j < i {
+			j = i
+		}
+		for ; j < len(pat) && q[j] > 1; j++ {
+			var ns, ne uint
+			var ok bool
+			if j == i {
+				ns, ne, ok = h.m.getBlockRange(pat[j])
+			} else {
+				ns, ne, ok = h.extend(pat[j], s, e)
+			}
+			if !ok || ns == ne {
+				break
+			}
+			s, e = ns, ne
+		}
+		if j == i {
+			continue
+		}
+		ms[i], rngs[i] = uint(j-i), []uint{s, e}
+
+		d := uint(j - i - 1)
+		if d < msRestart {
+			// restart from q[i+1]
+			j = i + 1
+			continue
+		}
+		h.lcp.once.Do(func() { h.lcp.LCP = lcp.New(h) })
+		s, e = h.lcp.PSV(s+1, d)-1, h.lcp.NSV(e, d)-1
+	}
+
+	return ms, rngs
+}
+
+// extend appends forward index byte b to range (s, e], returns the new range (s, e]
+func (h *hybrid) extend(b byte, s, e uint) (uint, uint, bool) {
+	offset, _, ok := h.m.getBlockRange(b)
+	if !ok {
+		return 0, 0, false
+	}
+
+	s = offset + blockRank(b, s/internal.SZ, h.m.super, h.m.bsz, h.m.char, h.m.hist) +
+		h.m.bsds[s/internal.SZ].Rank(b, s%internal.SZ, h.m.bbv[s/internal.SZ])
+	e = offset + blockRank(b, e/internal.SZ, h.m.super, h.m.bsz, h.m.char, h.m.hist) +
+		h.m.bsds[e/internal.SZ].Rank(b, e%internal.SZ, h.m.bbv[e/internal.SZ])
+	return s, e, true
+}
+
+func (h *hybrid) Offset(p uint) (uint, bool) {
+	if p >= h.cnt {
+		return 0, false
+	}
+
+	h.sa.once.Do(h.sampleOffsets)
+
+	// walk backward until a sampled position or a separator
+	for k := uint(0); ; k++ {
+		if off, ok := h.sa.find(p); ok {
+			return off + k, true
+		}
+		if p == 0 {
+			// p is the sentinel, before the text
+			return k - 1, k > 0
+		}
+		b, r, _ := h.Locate(p)
+		if b == 1 {
+			// note: separators are in text order in F, but not in L
+			return h.sa.seps[r-1] + k, true
+		}
+		p, _ = h.Select(b, r)
+	}
+}
+
+func (h *hybrid) Occurrences(pat string) []uint {
+	rng, ok := h.Search(pat)
+	if !ok {
+		return nil
+	}
+
+	offs := make([]uint, 0, rng[1]-rng[0])
+	for p := rng[0] + 1; p <= rng[1]; p++ {
+		off, _ := h.Offset(p)
+		// offset of the last char in pattern
+		offs = append(offs, off+1-uint(len(pat)))
+	}
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+
+	return offs
+}
+
+// sampleOffsets walks the whole text, samples text offsets of every saRate chars and separators
+func (h *hybrid) sampleOffsets() {
+	_, nxtOne, _ := h.m.getBlockRange(0)
+	for i, p := uint(0), uint(0); i+1 < h.cnt; i++ {
+		b, r := h.m.bsds[p/internal.SZ].Access(p%internal.SZ, h.m.bbv[p/internal.SZ])
+		if b == 1 {
+			// for separators, just go straight to the next one
+			nxtOne++
+			p = nxtOne
+			h.sa.seps = append(h.sa.seps, i)
+			continue
+		}
+
+		offset, _, _ := h.m.getBlockRange(b)
+		p = offset + r + blockRank(b, p/internal.SZ, h.m.super, h.m.bsz, h.m.char, h.m.hist)
+		if i%saRate == 0 {
+			h.sa.rows = append(h.sa.rows, p)
+			h.sa.offs = append(h.sa.offs, i)
+		}
+	}
+
+	sort.Sort(&h.sa)
+}
+
 func (h *hybrid) Size() (int, int) {
 	return len(h.hdr), len(h.bv)
 }
@@ -181,44 +445,163 @@ func (h *hybrid) GetBound(b byte) (uint, uint, bool) {
 }
 
 func (h *hybrid) Restore(w io.Writer) bool {
-	pgSZ := os.Getpagesize()
+	return h.RestoreTo(context.Background(), w, hfmi.DefaultRestore) == nil
+}
+
+func (h *hybrid) RestoreTo(ctx context.Context, w io.Writer, opts hfmi.RestoreOptions) error {
+	bw := bufio.NewWriterSize(w, os.Getpagesize())
+	if opts.Workers > 1 && h.Documents() > 1 {
+		return h.restoreParallel(ctx, bw, opts)
+	}
+
 	nxtZero, nxtOne, _ := h.m.getBlockRange(0)
 	nxtOne++
-	np, eod, buf, j := nxtZero, nxtOne, make([]byte, pgSZ, pgSZ), 0
+	np, eod := nxtZero, nxtOne
+
+	for i := uint(1); ; i++ {
+		if i%ctxRate == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
 
-loop:
-	for {
 		b, r := h.m.bsds[np/internal.SZ].Access(np%internal.SZ, h.m.bbv[np/internal.SZ])
 
+		var err error
 		switch b {
 		case 0:
+			if _, err = bw.Write(opts.Zero); err != nil {
+				return err
+			}
 			nxtZero++
 			if nxtZero == eod {
-				break loop
+				return bw.Flush()
 			}
 			np = nxtZero
 		case 1:
 			// for separators, just go straight to the next char
 			np = nxtOne
 			nxtOne++
-			buf[j] = ' '
+			_, err = bw.Write(opts.One)
 		default:
-			buf[j] = h.dict.ridx[b]
+			err = bw.WriteByte(h.dict.ridx[b])
 			offset, _, _ := h.m.getBlockRange(b)
 			np = offset + r + blockRank(b, np/internal.SZ, h.m.super, h.m.bsz, h.m.char, h.m.hist)
 		}
 
-		j = (j + 1) % pgSZ
-		if j == 0 {
-			w.Write(buf)
+		if err != nil {
+			return err
 		}
 	}
+}
 
-	if j > 0 {
-		w.Write(buf[:j])
+func (h *hybrid) Documents() uint {
+	// n separators separate n + 1 documents
+	s, e, _ := h.m.getBlockRange(1)
+	return e - s + 1
+}
+
+func (h *hybrid) RestoreDocument(ctx context.Context, w io.Writer, id uint) error {
+	if id >= h.Documents() {
+		return hfmi.ErrDocument
 	}
 
-	return true
+	bw := bufio.NewWriterSize(w, os.Getpagesize())
+	if err := h.document(ctx, bw, id); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// document writes id-th document to w, document 0 starts from the sentinel, others start from separators
+func (h *hybrid) document(ctx context.Context, w io.ByteWriter, id uint) error {
+	s, _, _ := h.m.getBlockRange(1)
+	for i, np := uint(1), s+id; ; i++ {
+		if i%ctxRate == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		b, r := h.m.bsds[np/internal.SZ].Access(np%internal.SZ, h.m.bbv[np/internal.SZ])
+		if b < 2 {
+			return nil
+		}
+		if err := w.WriteByte(h.dict.ridx[b]); err != nil {
+			return err
+		}
+		offset, _, _ := h.m.getBlockRange(b)
+		np = offset + r + blockRank(b, np/internal.SZ, h.m.super, h.m.bsz, h.m.char, h.m.hist)
+	}
+}
+
+type chunk struct {
+	b   []byte
+	err error
+}
+
+// restoreParallel decodes chunks of documents concurrently, each document is an independent walk,
+// chunks are written to bw in document order, at most workers chunks are in flight.
+func (h *hybrid) restoreParallel(ctx context.Context, bw *bufio.Writer, opts hfmi.RestoreOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	docs, workers := h.Documents(), uint(opts.Workers)
+	per := docs / (workers * 8)
+	if per == 0 {
+		per = 1
+	}
+
+	queue := make(chan chan chunk, workers)
+	go func() {
+		defer close(queue)
+		for beg := uint(0); beg < docs; beg += per {
+			end := beg + per
+			if end > docs {
+				end = docs
+			}
+
+			c := make(chan chunk, 1)
+			select {
+			case queue <- c:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(beg, end uint) {
+				var buf bytes.Buffer
+				for id := beg; id < end; id++ {
+					if id > 0 {
+						buf.Write(opts.One)
+					}
+					if err := h.document(ctx, &buf, id); err != nil {
+						c <- chunk{err: err}
+						return
+					}
+				}
+				c <- chunk{b: buf.Bytes()}
+			}(beg, end)
+		}
+	}()
+
+	for c := range queue {
+		r := <-c
+		if r.err != nil {
+			return r.err
+		}
+		if _, err := bw.Write(r.b); err != nil {
+			return err
+		}
+	}
+
+	// queue is closed early if ctx is cancelled by caller
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := bw.Write(opts.Zero); err != nil {
+		return err
+	}
+	return bw.Flush()
 }
 
 func (h *hybrid) ForwardExtractToChar(p uint, t byte) ([]byte, uint, bool) {
@@ -228,20 +611,47 @@ func (h *hybrid) ForwardExtractToChar(p uint, t byte) ([]byte, uint, bool) {
 		nt = 0
 	}
 
-	buf := bytes.NewBuffer(make([]byte, 0, h.cnt))
+	buf, p := h.forward([]byte{}, p, nt)
+	return buf, p, true
+}
+
+// forward appends chars to dst, walking BWT forward from p, until found forward index byte nt or 0
+func (h *hybrid) forward(dst []byte, p uint, nt byte) ([]byte, uint) {
 	for {
 		b, r := h.m.bsds[p/internal.SZ].Access(p%internal.SZ, h.m.bbv[p/internal.SZ])
 
 		if b == nt || b == 0 {
-			return buf.Bytes(), p, true
+			return dst, p
 		}
 
 		offset, _, _ := h.m.getBlockRange(b)
 		p = r + blockRank(b, p/internal.SZ, h.m.super, h.m.bsz, h.m.char, h.m.hist) + offset
 
-		buf.WriteByte(h.dict.ridx[b])
+		dst = append(dst, h.dict.ridx[b])
 	}
-	return nil, 0, false
+}
+
+func (h *hybrid) Rows(sep byte, fc uint) hfmi.RowIterator {
+	it := &rows{h: h, sep: h.dict.fidx[sep], fc: fc, ends: make([]int, fc), fields: make([][]byte, fc)}
+
+	// get number of rows, note: n separators separate n + 1 fields
+	if fc == 0 {
+		it.err = hfmi.ErrFieldCount
+		return it
+	}
+	beg, end, ok := h.GetBound(sep)
+	if !ok {
+		it.err = hfmi.ErrSeparator
+		return it
+	}
+	if sep != 1 {
+		// note: fields are located by rank of separators in F, only byte 1 is in text order
+		it.err = hfmi.ErrRowSeparator
+		return it
+	}
+	it.rows = (end - beg + 1) / fc
+
+	return it
 }
 
 func (h *hybrid) BackwardExtractToChar(p uint, t byte) ([]byte, uint, bool) {
@@ -346,8 +756,8 @@ func (h *hybrid) ExtractAllFields(sep byte, fc uint) ([][][]byte, bool) {
 		return nil, false
 	}
 
-	// get number of rows
-	ret := make([][][]byte, (end-beg)/fc, (end-beg)/fc)
+	// get number of rows, note: n separators separate n + 1 fields
+	ret := make([][][]byte, (end-beg+1)/fc, (end-beg+1)/fc)
 	for ith := range ret {
 		ret[ith] = make([][]byte, fc, fc)
 		for j := uint(0); j < fc; j++ {
@@ -381,5 +791,4 @@ func (h *hybrid) ExtractRange(from, to uint) ([]byte, bool) {
 		offset, _, _ := h.m.getBlockRange(b)
 		from = offset + r + blockRank(b, from/internal.SZ, h.m.super, h.m.bsz, h.m.char, h.m.hist)
 	}
-	return nil, false
 }
@@ -20,11 +20,23 @@
 package hybrid
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io/ioutil"
+	"math/rand"
 	"path"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/rleiwang/sa"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/internal/testutil"
 )
 
 func TestAccess(t *testing.T) {
@@ -95,6 +107,50 @@ func BenchmarkRank(b *testing.B) {
 	}
 }
 
+// countOverlapped counts occurrences of p in text, including overlapped ones
+func countOverlapped(text, p string) int {
+	cnt := 0
+	for i := strings.Index(text, p); i >= 0; i = strings.Index(text, p) {
+		cnt++
+		text = text[i+1:]
+	}
+	return cnt
+}
+
+func BenchmarkRestoreTo(b *testing.B) {
+	index := New(testutil.Words(11, 200000, 1))
+	for _, workers := range []int{1, runtime.GOMAXPROCS(0)} {
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				index.RestoreTo(context.Background(), ioutil.Discard, hfmi.RestoreOptions{Workers: workers})
+			}
+		})
+	}
+}
+
+// randomDNA generates n nucleotides, a space every 997 chars
+func randomDNA(seed int64, n int) []byte {
+	r := rand.New(rand.NewSource(seed))
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = "acgt"[r.Intn(4)]
+		if i%997 == 996 {
+			buf[i] = ' '
+		}
+	}
+	return buf
+}
+
+// splice joins windows of n bytes of t at offsets with a mismatch, spaces are separators
+func splice(t []byte, n int, offs ...int) string {
+	var buf bytes.Buffer
+	for _, o := range offs {
+		buf.Write(bytes.ReplaceAll(t[o:o+n], []byte{' '}, []byte{1}))
+		buf.WriteByte('#')
+	}
+	return buf.String()
+}
+
 func readTestFile(file string) []byte {
 	content, err := ioutil.ReadFile(path.Join("../testdata", file))
 	if err != nil {
@@ -102,3 +158,384 @@ func readTestFile(file string) []byte {
 	}
 	return content
 }
+
+func TestSearchMany(t *testing.T) {
+	type args struct {
+		t        []byte
+		patterns []string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"textbook", args{[]byte("tobeornottobethatisthequestion"),
+			[]string{"t", "to", "tob", "tobe", "the", "that", "q", "question", "x", "tx", "", "to"}}},
+		{"words", args{testutil.Words(1, 20000, ' '), nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns := tt.args.patterns
+			if patterns == nil {
+				patterns = strings.Fields(string(tt.args.t))
+				for i, p := range patterns {
+					patterns = append(patterns, p[:len(p)/2], p+"s")
+					if i > 2000 {
+						break
+					}
+				}
+			}
+			fmi := New(bytes.ReplaceAll(tt.args.t, []byte{' '}, []byte{1}))
+			got := fmi.SearchMany(patterns)
+			for _, p := range patterns {
+				want, ok := fmi.Search(p)
+				if rng, found := got[p]; found != ok || !reflect.DeepEqual(rng, want) {
+					t.Errorf("SearchMany(%q) = %v, %v, want %v, %v", p, rng, found, want, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchingStatistics(t *testing.T) {
+	type args struct {
+		t []byte
+		q string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"textbook", args{[]byte("tobeornottobethatisthequestion"), "xtobethequiet"}},
+		{"miss", args{[]byte("tobeornottobethatisthequestion"), "xyz"}},
+		{"words", args{testutil.Words(2, 2000, ' '), "thequickbrownfoxjumpsoverthelazydog"}},
+		{"repeats", args{bytes.Repeat([]byte("tobeornottobe"), 50), "ornottobetobeornottobethatobeornottobeornottoxbeornottobeornottobeornot"}},
+		{"spliced", args{testutil.Words(4, 3000, ' '), splice(testutil.Words(4, 3000, ' '), 60, 100, 7000, 350, 12000, 9000)}},
+		{"genome", args{randomDNA(5, 20000), splice(randomDNA(5, 20000), 80, 100, 19000, 950, 4000, 12345, 4040)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := string(bytes.ReplaceAll(tt.args.t, []byte{' '}, []byte{1}))
+			fmi := New([]byte(text))
+			ms := fmi.MatchingStatistics(tt.args.q)
+			for i := range tt.args.q {
+				want := 0
+				// separators never match
+				for want < len(tt.args.q)-i && tt.args.q[i+want] > 1 && strings.Contains(text, tt.args.q[i:i+want+1]) {
+					want++
+				}
+				if ms[i] != uint(want) {
+					t.Errorf("MatchingStatistics(%q)[%d] = %v, want %v", tt.args.q, i, ms[i], want)
+				}
+			}
+
+			for _, m := range fmi.MEMs(tt.args.q, 2) {
+				p := tt.args.q[m.Offset : m.Offset+m.Len]
+				if m.Len < 2 || m.Len != ms[m.Offset] || (m.Offset > 0 && ms[m.Offset-1] > m.Len) {
+					t.Errorf("MEMs(%q) = %v is not maximal", tt.args.q, m)
+				}
+				if cnt := countOverlapped(text, p); m.Range[1]-m.Range[0] != uint(cnt) {
+					t.Errorf("MEMs(%q) = %v, count %v, want %v", tt.args.q, m, m.Range[1]-m.Range[0], cnt)
+				}
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	type args struct {
+		t []byte
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"textbook", args{[]byte("tobeornottobethatisthequestion")}},
+		{"words", args{testutil.Words(3, 5000, 1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fmi := New(tt.args.t)
+			for i := uint(0); i < fmi.Len(); i++ {
+				c, r, _ := fmi.Access(i)
+				if p, ok := fmi.Select(c, r); !ok || p != i {
+					t.Errorf("Select(%v, %v) = %v, %v, want %v", c, r, p, ok, i)
+				}
+			}
+			if p, ok := fmi.Select('t', fmi.Len()); ok {
+				t.Errorf("Select('t', %v) = %v, %v, want false", fmi.Len(), p, ok)
+			}
+		})
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	type args struct {
+		t        []byte
+		patterns []string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"textbook", args{[]byte("tobeornottobethatisthequestion"), []string{"t", "to", "tobe", "n", "question", "x"}}},
+		{"words", args{testutil.Words(4, 5000, 1), []string{"a", "ab", "xyz", "q"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := string(tt.args.t)
+			fmi := New(tt.args.t)
+			for _, p := range tt.args.patterns {
+				var want []uint
+				for i := 0; i+len(p) <= len(text); i++ {
+					if text[i:i+len(p)] == p {
+						want = append(want, uint(i))
+					}
+				}
+				if got := fmi.Occurrences(p); !reflect.DeepEqual(got, want) {
+					t.Errorf("Occurrences(%q) = %v, want %v", p, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRows(t *testing.T) {
+	type args struct {
+		t  []byte
+		fc uint
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"pairs", args{[]byte("ab\x01cd\x01ef\x01gh\x01ij\x01kl"), 2}},
+		{"words", args{testutil.Words(5, 3000, 1), 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := bytes.Split(tt.args.t, []byte{1})
+			fmi := New(tt.args.t)
+
+			it, i := fmi.Rows(1, tt.args.fc), uint(0)
+			if row := it.Row(); row != 0 {
+				t.Errorf("Row() before Next = %v, want 0", row)
+			}
+			for ; it.Next(); i++ {
+				if row := it.Row(); row != i || !reflect.DeepEqual(it.Fields(), want[i*tt.args.fc:(i+1)*tt.args.fc]) {
+					t.Errorf("Row() = %v, Fields() = %q, want %v, %q", row, it.Fields(), i, want[i*tt.args.fc:(i+1)*tt.args.fc])
+				}
+			}
+			if it.Err() != nil || i != uint(len(want))/tt.args.fc {
+				t.Errorf("Rows() = %v rows, %v, want %v rows", i, it.Err(), len(want)/int(tt.args.fc))
+			}
+
+			// resume from the last row
+			last := uint(len(want))/tt.args.fc - 1
+			if !it.Seek(last) || !it.Next() || it.Row() != last || it.Next() {
+				t.Errorf("Seek(%v) = %v", last, it.Row())
+			}
+		})
+	}
+
+	if it := New([]byte("abc")).Rows(',', 2); it.Next() || it.Err() != hfmi.ErrSeparator {
+		t.Errorf("Rows(',') error = %v, want %v", it.Err(), hfmi.ErrSeparator)
+	}
+	if it := New([]byte("a,b\x01c,d")).Rows(',', 2); it.Next() || it.Err() != hfmi.ErrRowSeparator {
+		t.Errorf("Rows(',') error = %v, want %v", it.Err(), hfmi.ErrRowSeparator)
+	}
+	if it := New([]byte("a\x01b")).Rows(1, 0); it.Next() || it.Err() != hfmi.ErrFieldCount {
+		t.Errorf("Rows(1, 0) error = %v, want %v", it.Err(), hfmi.ErrFieldCount)
+	}
+}
+
+func TestExtractAllFields(t *testing.T) {
+	type args struct {
+		t  []byte
+		fc uint
+	}
+	tests := []struct {
+		name string
+		args args
+		want [][]string
+	}{
+		{"pairs", args{[]byte("ab\x01cd\x01ef\x01gh"), 2}, [][]string{{"ab", "cd"}, {"ef", "gh"}}},
+		{"single", args{[]byte("ab\x01cd\x01ef"), 1}, [][]string{{"ab"}, {"cd"}, {"ef"}}},
+		{"ragged", args{[]byte("ab\x01cd\x01ef\x01gh\x01ij"), 2}, [][]string{{"ab", "cd"}, {"ef", "gh"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, ok := New(tt.args.t).ExtractAllFields(1, tt.args.fc)
+			got := make([][]string, len(rows))
+			for i, row := range rows {
+				for _, f := range row {
+					got[i] = append(got[i], string(f))
+				}
+			}
+			// note: the text doesn't end with separator, the last row used to be dropped
+			if !ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractAllFields(%v) = %q, %v, want %q", tt.args.fc, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errors.New("fail")
+}
+
+func TestRestoreTo(t *testing.T) {
+	type args struct {
+		t    []byte
+		opts hfmi.RestoreOptions
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"default", args{[]byte("ab\x01cd\x01ef"), hfmi.DefaultRestore}, "ab cd ef"},
+		{"lines", args{[]byte("ab\x01cd\x01ef"), hfmi.RestoreOptions{Zero: []byte{'\n'}, One: []byte{'\t'}}}, "ab\tcd\tef\n"},
+		{"original", args{[]byte("ab\x01cd\x01ef"), hfmi.RestoreOptions{One: []byte{1}}}, "ab\x01cd\x01ef"},
+		{"words", args{testutil.Words(7, 5000, 1), hfmi.RestoreOptions{One: []byte{1}}}, ""},
+		{"parallel", args{[]byte("ab\x01cd\x01ef"), hfmi.RestoreOptions{Zero: []byte{'\n'}, One: []byte{'\t'}, Workers: 4}}, "ab\tcd\tef\n"},
+		{"parallel words", args{testutil.Words(7, 5000, 1), hfmi.RestoreOptions{One: []byte{1}, Workers: 4}}, ""},
+		{"parallel sparse", args{testutil.Skewed(7, 1<<16), hfmi.RestoreOptions{One: []byte{1}, Workers: 8}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == "" {
+				want = string(tt.args.t)
+			}
+			var b bytes.Buffer
+			if err := New(tt.args.t).RestoreTo(context.Background(), &b, tt.args.opts); err != nil || b.String() != want {
+				t.Errorf("RestoreTo() = %q, %v, want %q", b.String(), err, want)
+			}
+		})
+	}
+
+	text := testutil.Words(7, 5000, 1)
+	fmi := New(text)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, workers := range []int{0, 4} {
+		if err := fmi.RestoreTo(context.Background(), failWriter{}, hfmi.RestoreOptions{Workers: workers}); err == nil {
+			t.Errorf("RestoreTo(workers %v) error = %v, want write error", workers, err)
+		}
+		if err := fmi.RestoreTo(ctx, ioutil.Discard, hfmi.RestoreOptions{Workers: workers}); err != context.Canceled {
+			t.Errorf("RestoreTo(workers %v) error = %v, want %v", workers, err, context.Canceled)
+		}
+	}
+}
+
+func TestRestoreDocument(t *testing.T) {
+	text := testutil.Words(9, 2000, 1)
+	docs := bytes.Split(text, []byte{1})
+	fmi := New(text)
+
+	if fmi.Documents() != uint(len(docs)) {
+		t.Errorf("Documents() = %v, want %v", fmi.Documents(), len(docs))
+	}
+	for i, d := range docs {
+		var b bytes.Buffer
+		if err := fmi.RestoreDocument(context.Background(), &b, uint(i)); err != nil || !bytes.Equal(b.Bytes(), d) {
+			t.Errorf("RestoreDocument(%v) = %q, %v, want %q", i, b.Bytes(), err, d)
+		}
+	}
+
+	if err := fmi.RestoreDocument(context.Background(), ioutil.Discard, uint(len(docs))); err != hfmi.ErrDocument {
+		t.Errorf("RestoreDocument() error = %v, want %v", err, hfmi.ErrDocument)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	type args struct {
+		t []byte
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"single", args{bytes.Repeat([]byte("a"), 1000)}},
+		{"words", args{testutil.Words(13, 5000, 1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fmi := New(tt.args.t)
+			r := fmi.Inspect()
+
+			hdr, body := fmi.Size()
+			if r.Len != fmi.Len() || r.HeaderBytes != uint(hdr) || r.BodyBytes != uint(body) || r.Blocks != (r.Len+r.BlockSize-1)/r.BlockSize {
+				t.Errorf("Inspect() = %+v, want len %v, size %v, %v", r, fmi.Len(), hdr, body)
+			}
+
+			blocks, hdrs, bodies, entropy := uint(0), uint(cntsz), uint(0), uint(0)
+			for _, e := range r.Encodings {
+				blocks, hdrs, bodies = blocks+e.Blocks, hdrs+e.HeaderBytes, bodies+e.BodyBytes
+			}
+			for _, n := range r.Entropy {
+				entropy += n
+			}
+			if blocks != r.Blocks || entropy != r.Blocks || hdrs != r.HeaderBytes || bodies != r.BodyBytes {
+				t.Errorf("Inspect() = %+v, blocks %v, entropy %v, header %v, body %v", r, blocks, entropy, hdrs, bodies)
+			}
+		})
+	}
+}
+
+// TestNewConcurrent builds of more goroutines than the former segment cache must not share encoder buffers
+func TestNewConcurrent(t *testing.T) {
+	text := testutil.Words(31, 4000, 1)
+	want := New(append([]byte{}, text...)).Bytes()
+
+	var wg sync.WaitGroup
+	got := make([][]byte, 4*runtime.GOMAXPROCS(0))
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			got[i] = New(append([]byte{}, text...)).Bytes()
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range got {
+		if !bytes.Equal(got[i], want) {
+			t.Fatalf("New() of goroutine %d differs", i)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	type args struct {
+		a, b []byte
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"chars", args{[]byte("ab"), []byte("ba")}},
+		{"documents", args{[]byte("zz\x01a\x01zz"), []byte("zz\x01b")}},
+		{"disjoint", args{[]byte("abc"), []byte("xyz\x01xyz")}},
+		{"words", args{testutil.Words(17, 3000, 1), testutil.Words(19, 2000, 1)}},
+		{"smaller a", args{testutil.Words(23, 500, 1), testutil.Words(29, 4000, 1)}},
+		{"repeats", args{bytes.Repeat([]byte("abcab"), 300), bytes.Repeat([]byte("abcab\x01"), 99)[:593]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := append(append(append([]byte{}, tt.args.a...), 1), tt.args.b...)
+			want := New(append([]byte{}, text...))
+			got := Merge(New(append([]byte{}, tt.args.a...)), New(append([]byte{}, tt.args.b...)))
+			if !bytes.Equal(got.Bytes(), want.Bytes()) || !bytes.Equal(got.Dictionary(), want.Dictionary()) || got.Len() != want.Len() {
+				t.Errorf("Merge() differs from New()")
+			}
+
+			var b bytes.Buffer
+			if err := got.RestoreTo(context.Background(), &b, hfmi.RestoreOptions{One: []byte{1}}); err != nil || !bytes.Equal(b.Bytes(), text) {
+				t.Errorf("Merge() restores %q, want %q", b.Bytes(), text)
+			}
+		})
+	}
+}
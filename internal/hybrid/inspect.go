@@ -0,0 +1,84 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package hybrid
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/internal"
+)
+
+var edtNames = [...]string{single: "single", runlen: "runlen", sparse: "sparse", lwc: "lwc"}
+
+func (h *hybrid) Inspect() hfmi.Report {
+	r := hfmi.Report{
+		Len:         h.cnt,
+		BlockSize:   internal.SZ,
+		Blocks:      uint(len(h.m.bsz)),
+		HeaderBytes: uint(len(h.hdr)),
+		BodyBytes:   uint(len(h.bv)),
+		SuperBlocks: uint(len(h.m.super)),
+		// rank of each char and offset
+		SuperBytes: uint(len(h.m.super)) * (αsz + 1) * bits.UintSize / 8,
+		Encodings:  make([]hfmi.Encoding, len(edtNames)),
+	}
+	for i, n := range edtNames {
+		r.Encodings[i].Name = n
+	}
+
+	for i, j := cntsz, uint(0); i < len(h.hdr); {
+		t, cnt, sz, k := blockHeader(h.hdr, i)
+		// chars and freqs follow
+		next := k + 1 + 2*int(cnt)
+
+		e := &r.Encodings[t]
+		e.Blocks++
+		e.HeaderBytes += uint(next - i)
+		e.BodyBytes += sz
+		e.Symbols += cnt
+
+		b := int(entropy(h.m.hist[j : j+cnt]))
+		if b >= len(r.Entropy) {
+			b = len(r.Entropy) - 1
+		}
+		r.Entropy[b]++
+
+		i, j = next, j+cnt
+	}
+
+	return r
+}
+
+// entropy returns empirical entropy in bits per symbol of histogram
+func entropy(hist []uint16) float64 {
+	n := 0.0
+	for _, f := range hist {
+		n += float64(f)
+	}
+
+	h := 0.0
+	for _, f := range hist {
+		p := float64(f) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package hybrid
+
+import (
+	"github.com/rleiwang/hfmi"
+)
+
+// Merge returns FMI index of text a + byte 1 + text b, by interleaving BWT of a and b, without suffix sorting.
+//
+// Separators sort by text offset, so the order of rows of a document depends only on the document
+// and its preceding documents, rows of a keep their order, so do rows of b. Ties go to a, since
+// separators of a precede the separator joins a and b. The smaller one is walked forward in text order,
+// the insertion rank of its row Xc, i.e. the number of rows of the larger one precede it, follows the
+// one of row X by LF on the larger one:
+//
+//	ins(Xc) = rows of larger before bucket c + rank of c in L of larger before ins(X)
+//
+// rows at the beginning or after a separator follow rows of a in bucket 0 and 1, and precede rows of b.
+// The merge costs O(|a| + |b|) rank operations. Both a and b must not be empty.
+func Merge(a, b hfmi.FMI) hfmi.FMI {
+	n := a.Len() + b.Len()
+
+	// rows of small are marked in merged order
+	small, large, ins := b, a, uint(0)
+	if a.Len() < b.Len() {
+		small, large = a, b
+	}
+	lt := bucketStarts(large)
+	if small == b {
+		ins = lt[2]
+	}
+
+	marks := make([]uint64, n/64+1)
+	mark := func(p, ins uint) {
+		marks[(p+ins)/64] |= 1 << ((p + ins) % 64)
+	}
+	mark(0, ins)
+	for p, sep := uint(0), uint(0); ; {
+		c, r, _ := small.Access(p)
+		if c == 0 {
+			break
+		}
+		if c == 1 {
+			// note: separators are in text order in F, but not in L
+			sep++
+			r = sep
+			if small == b {
+				ins = lt[2]
+			} else {
+				ins = 0
+			}
+		} else {
+			ins = lt[c] + rankBefore(large, c, ins)
+		}
+		s, _, _ := small.GetBound(c)
+		p = s + r
+		mark(p, ins)
+	}
+
+	bwt := make([]byte, n)
+	for k, p, q := uint(0), uint(0), uint(0); k < n; k++ {
+		if marks[k/64]&(1<<(k%64)) != 0 {
+			bwt[k] = lOf(small, p, a)
+			p++
+		} else {
+			bwt[k] = lOf(large, q, a)
+			q++
+		}
+	}
+
+	chars := union(a.Dictionary(), b.Dictionary())
+	return buildFMI(bwt, &dictionary{fidx: newForwardIndex(chars), ridx: chars})
+}
+
+// lOf returns L of row p of f, the end of a is followed by the separator joins a and b
+func lOf(f hfmi.FMI, p uint, a hfmi.FMI) byte {
+	l, _, _ := f.Access(p)
+	if l == 0 && f == a {
+		return 1
+	}
+	return l
+}
+
+// bucketStarts returns number of rows before bucket c, for every c
+func bucketStarts(f hfmi.FMI) []uint {
+	lt := make([]uint, αsz)
+	next := f.Len()
+	for c := αsz - 1; c >= 0; c-- {
+		if s, _, ok := f.GetBound(byte(c)); ok && c > 0 {
+			next = s + 1
+		}
+		lt[c] = next
+	}
+	return lt
+}
+
+// rankBefore returns number of c in L of rows before p
+func rankBefore(f hfmi.FMI, c byte, p uint) uint {
+	if _, _, ok := f.GetBound(c); !ok || p == 0 {
+		return 0
+	}
+	r, _ := f.Rank(c, p-1)
+	return r
+}
+
+// union returns sorted chars of both dictionaries, 0 and 1 are always the first two
+func union(x, y []byte) []byte {
+	var set [αsz]bool
+	for _, c := range x {
+		set[c] = true
+	}
+	for _, c := range y {
+		set[c] = true
+	}
+
+	chars := []byte{0, 1}
+	for c := 2; c < αsz; c++ {
+		if set[c] {
+			chars = append(chars, byte(c))
+		}
+	}
+	return chars
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package hybrid
+
+import "github.com/rleiwang/hfmi"
+
+// rows iterates rows of fc fields separated by sep, fields of a row share the same buffer
+type rows struct {
+	h      *hybrid
+	sep    byte     // forward index of separator
+	fc     uint     // number of fields per row
+	rows   uint     // number of rows
+	row    uint     // current row
+	next   uint     // next row
+	buf    []byte   // buffer of fields
+	ends   []int    // end offsets of fields in buffer
+	fields [][]byte // fields of current row
+	err    error
+}
+
+func (it *rows) Next() bool {
+	if it.err != nil || it.next >= it.rows {
+		return false
+	}
+
+	// reuse buffer, fields are offsets into buffer until the row is complete
+	it.buf = it.buf[:0]
+	for j := uint(0); j < it.fc; j++ {
+		it.buf, _ = it.h.forward(it.buf, it.next*it.fc+j, it.sep)
+		it.ends[j] = len(it.buf)
+	}
+
+	beg := 0
+	for j, e := range it.ends {
+		it.fields[j], beg = it.buf[beg:e:e], e
+	}
+	it.row = it.next
+	it.next++
+
+	return true
+}
+
+func (it *rows) Fields() [][]byte {
+	return it.fields
+}
+
+func (it *rows) Row() uint {
+	return it.row
+}
+
+func (it *rows) Seek(row uint) bool {
+	if it.err != nil || row >= it.rows {
+		return false
+	}
+	it.next = row
+	return true
+}
+
+func (it *rows) Err() error {
+	return it.err
+}
+
+var _ hfmi.RowIterator = (*rows)(nil)
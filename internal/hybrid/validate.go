@@ -0,0 +1,199 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package hybrid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/rleiwang/hfmi/internal"
+)
+
+// ValidateText checks text t of documents separated by byte 1, so that New doesn't panic. Byte 0 is the end of
+// text in BWT, and empty documents, i.e. leading, trailing or consecutive separators, are not indexed.
+func ValidateText(t []byte) error {
+	if len(t) == 0 {
+		return errors.New("empty text")
+	}
+	if i := bytes.IndexByte(t, 0); i >= 0 {
+		return fmt.Errorf("byte 0 at offset %d", i)
+	}
+	if t[0] == 1 || t[len(t)-1] == 1 {
+		return errors.New("empty document at the beginning or the end of text")
+	}
+	if i := bytes.Index(t, []byte{1, 1}); i >= 0 {
+		return fmt.Errorf("empty document at offset %d", i+1)
+	}
+	return nil
+}
+
+// Validate checks d serialized by Bytes() of cnt chars with dictionary ridx, so that Build doesn't panic,
+// and the body of each block decodes to the chars and frequencies of its header. d may be of version 0 layout.
+func Validate(cnt uint, ridx, d []byte) error {
+	if len(ridx) < 2 || ridx[0] != 0 || ridx[1] != 1 {
+		return errors.New("dictionary must start with byte 0 and 1")
+	}
+	for i := 2; i < len(ridx); i++ {
+		if ridx[i] <= ridx[i-1] {
+			return errors.New("dictionary is not in ascending order")
+		}
+	}
+	if cnt == 0 {
+		return errors.New("empty index")
+	}
+
+	if len(d) < lensz {
+		return errors.New("missing header length")
+	}
+	d = upgrade(d)
+	n := binary.LittleEndian.Uint64(d)
+	if n < cntsz || n > uint64(len(d)-lensz) {
+		return fmt.Errorf("header length %d out of range", n)
+	}
+	hdr, bv := d[lensz:lensz+n], d[lensz+n:]
+
+	i, end, chars, zeros := cntsz, uint(0), uint64(0), uint(0)
+	blocks := (cnt + internal.SZ - 1) / internal.SZ
+	for blk := uint(0); blk < blocks; blk++ {
+		size := uint(internal.SZ)
+		if blk == blocks-1 {
+			size = cnt - blk*internal.SZ
+		}
+
+		// blockHeader reads at most 3 bytes
+		if len(hdr)-i < 3 {
+			return fmt.Errorf("block %d: header truncated", blk)
+		}
+		t, k, sz, j := blockHeader(hdr, i)
+		if (t == single) != (k == 1) {
+			return fmt.Errorf("block %d: %d chars of encoding %d", blk, k, t)
+		}
+		if j+int(2*k) >= len(hdr) {
+			return fmt.Errorf("block %d: header truncated", blk)
+		}
+
+		hist := make([]uint, αsz)
+		c, f := make([]byte, k), make([]uint16, k)
+		total := uint(0)
+		for s := uint(0); s < k; s++ {
+			j++
+			c[s] = hdr[j]
+			j++
+			if f[s] = uint16(hdr[j]); f[s] == 0 {
+				f[s] = 256
+			}
+			if int(c[s]) >= len(ridx) || (s > 0 && c[s] <= c[s-1]) {
+				return fmt.Errorf("block %d: invalid char %d", blk, c[s])
+			}
+			hist[c[s]] = uint(f[s])
+			total += uint(f[s])
+		}
+		if total != size {
+			return fmt.Errorf("block %d: %d chars, want %d", blk, total, size)
+		}
+		zeros += hist[0]
+		chars += uint64(k)
+		i = j + 1
+
+		if t == single {
+			continue
+		}
+		if end+sz > uint(len(bv)) {
+			return fmt.Errorf("block %d: body out of range", blk)
+		}
+		if err := validateBody(t, c, f, bv[end:end+sz], size, hist); err != nil {
+			return fmt.Errorf("block %d: %w", blk, err)
+		}
+		end += sz
+	}
+
+	if i != len(hdr) || end != uint(len(bv)) {
+		return errors.New("trailing bytes")
+	}
+	if chars != binary.LittleEndian.Uint64(hdr) {
+		return fmt.Errorf("%d chars in header, want %d", binary.LittleEndian.Uint64(hdr), chars)
+	}
+	if zeros != 1 {
+		return fmt.Errorf("%d end of text, want 1", zeros)
+	}
+	return nil
+}
+
+// validateBody decodes body bv of block of size chars, and checks it against frequencies hist
+func validateBody(t edt, chars []byte, freq []uint16, bv []byte, size uint, hist []uint) error {
+	decoded := make([]uint, αsz)
+	switch t {
+	case runlen:
+		if len(bv)%2 != 0 {
+			return errors.New("odd runlen body")
+		}
+		n := uint(0)
+		for i := 0; i < len(bv); i += 2 {
+			if bv[i+1] == 0 {
+				return errors.New("empty run")
+			}
+			decoded[bv[i]] += uint(bv[i+1])
+			n += uint(bv[i+1])
+		}
+		if n != size {
+			return fmt.Errorf("runs of %d chars, want %d", n, size)
+		}
+	case sparse:
+		if len(bv)%2 != 0 {
+			return errors.New("odd sparse body")
+		}
+		mfc := findMostFreqChar(chars, freq)
+		for i := 0; i < len(bv); i += 2 {
+			if bv[i] == mfc || uint(bv[i+1]) >= size || (i > 0 && bv[i+1] <= bv[i-1]) {
+				return fmt.Errorf("invalid sparse char %d at %d", bv[i], bv[i+1])
+			}
+			decoded[bv[i]]++
+		}
+		decoded[mfc] += size - uint(len(bv)/2)
+	case lwc:
+		w := uint(8)
+		if len(chars) < 3 {
+			w = 1
+		} else if len(chars) < 5 {
+			w = 2
+		} else if len(chars) < 17 {
+			w = 4
+		}
+		if uint(len(bv)) != (size*w+7)/8 {
+			return fmt.Errorf("lwc body of %d bytes", len(bv))
+		}
+		for p := uint(0); p < size; p++ {
+			c := bv[p*w/8] >> (p * w % 8) & byte(1<<w-1)
+			if int(c) >= len(chars) {
+				return fmt.Errorf("invalid lwc char %d", c)
+			}
+			decoded[chars[c]]++
+		}
+	}
+
+	for c, n := range decoded {
+		if n != hist[c] {
+			return fmt.Errorf("%d of char %d, want %d", n, c, hist[c])
+		}
+	}
+	return nil
+}
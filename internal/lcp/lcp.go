@@ -0,0 +1,271 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package lcp
+
+import (
+	"math/bits"
+	"sort"
+
+	"github.com/rleiwang/hfmi"
+)
+
+const bsz = 64 // rmq block size
+
+// LCP compressed LCP array of an index, lcp[row] is the longest common suffix of prefixes at row-1 and row.
+// values are kept in text order as PLCP bit vector (2n bits), lcp at a row is PLCP at its sampled text offset,
+// with block minimums for range minimum query on top of it.
+// note: separators (byte 0 and 1) are never part of a common suffix.
+type LCP struct {
+	fmi  hfmi.FMI
+	n    uint
+	plcp bitVector
+	low  []uint   // minimum lcp of each block
+	mins [][]uint // sparse table of block minimum positions, mins[k][b] -> min of blocks [b, b + 2^k)
+}
+
+// New builds LCP array of the index, it walks the whole text twice and keeps a copy of text during the build
+func New(fmi hfmi.FMI) *LCP {
+	n := fmi.Len()
+	if n <= 1 {
+		// sentinel only
+		return &LCP{fmi: fmi, n: 1, low: []uint{0}, mins: [][]uint{{0}}}
+	}
+
+	// forward walk from the sentinel, rows of the prefixes before separators
+	text, seps := make([]byte, n-1), []uint(nil)
+	p := uint(0)
+	for i := uint(0); i < n-1; i++ {
+		a, r, _ := fmi.Access(p)
+		if a == 1 {
+			// note: separators are in text order in F, but not in L
+			seps = append(seps, p)
+			r = uint(len(seps))
+		}
+		s, _, _ := fmi.GetBound(a)
+		text[i], p = a, s+r
+	}
+
+	l := &LCP{fmi: fmi, n: n, plcp: newBitVector(2 * n), low: make([]uint, (n+bsz-1)/bsz)}
+	for b := range l.low {
+		l.low[b] = ^uint(0)
+	}
+	pos := make([]uint, len(l.low))
+	l.low[0], pos[0] = 0, 0
+
+	// Kasai et al. on reversed text, from the last prefix backward, PLCP[x-1] >= PLCP[x] - 1,
+	// hence PLCP[x] + i is non-decreasing for i = n-2-x, unary coded as zeros of the difference and a one.
+	h := uint(0)
+	for i := uint(0); i < n-1; i++ {
+		x := n - 2 - i
+		if p <= 1 {
+			// previous row is the sentinel
+			h = 0
+		} else {
+			y, _ := fmi.Offset(p - 1)
+			for h <= x && h <= y && text[x-h] == text[y-h] && text[y-h] > 1 {
+				h++
+			}
+		}
+
+		// i-th one is at PLCP[x] + 2i
+		l.plcp.set(h + 2*i)
+
+		if b := p / bsz; h < l.low[b] || (h == l.low[b] && p < pos[b]) {
+			l.low[b], pos[b] = h, p
+		}
+		if h > 0 {
+			h--
+		}
+
+		if x > 0 {
+			b, r, _ := fmi.Locate(p)
+			if b == 1 {
+				p = seps[r-1]
+			} else {
+				p, _ = fmi.Select(b, r)
+			}
+		}
+	}
+	l.plcp.index()
+
+	l.mins = append(l.mins, pos)
+	nb := uint(len(pos))
+	for k := uint(1); 1<<k <= nb; k++ {
+		prev, level := l.mins[k-1], make([]uint, nb-1<<k+1)
+		for b := range level {
+			p, q := prev[b], prev[uint(b)+1<<(k-1)]
+			if l.low[q/bsz] < l.low[p/bsz] {
+				p = q
+			}
+			level[b] = p
+		}
+		l.mins = append(l.mins, level)
+	}
+
+	return l
+}
+
+// Len returns number of rows
+func (l *LCP) Len() uint {
+	return l.n
+}
+
+// At returns lcp at row p, zero if p is out of range
+func (l *LCP) At(p uint) uint {
+	if p == 0 || p >= l.n {
+		return 0
+	}
+	x, _ := l.fmi.Offset(p)
+	return l.PLCP(x)
+}
+
+// PLCP returns lcp at the row of prefix ends at text offset x
+func (l *LCP) PLCP(x uint) uint {
+	i := l.n - 2 - x
+	return l.plcp.select1(i+1) - 2*i
+}
+
+// RMQ returns position of leftmost minimum in [i, j]
+func (l *LCP) RMQ(i, j uint) uint {
+	bi, bj := i/bsz, j/bsz
+	if bi == bj {
+		return l.scan(i, j)
+	}
+
+	// partial blocks at both ends
+	p := l.minOf(l.scan(i, bi*bsz+bsz-1), l.scan(bj*bsz, j))
+	if bi+1 < bj {
+		k := log2(bj - bi - 1)
+		q, r := l.mins[k][bi+1], l.mins[k][bj-1<<k]
+		if l.low[r/bsz] < l.low[q/bsz] {
+			q = r
+		}
+		p = l.minOf(p, q)
+	}
+
+	return p
+}
+
+// PSV returns the largest position p <= i, lcp[p] < d, zero if no such position
+func (l *LCP) PSV(i, d uint) uint {
+	for ; i%bsz != 0; i-- {
+		if l.At(i) < d {
+			return i
+		}
+	}
+	if i == 0 || l.At(i) < d {
+		return i
+	}
+
+	// skip blocks whose minimum is no less than d
+	for b := i/bsz - 1; ; b-- {
+		if l.low[b] < d {
+			for p := b*bsz + bsz - 1; ; p-- {
+				if l.At(p) < d {
+					return p
+				}
+			}
+		}
+		if b == 0 {
+			return 0
+		}
+	}
+}
+
+// NSV returns the smallest position p > i, lcp[p] < d, Len if no such position
+func (l *LCP) NSV(i, d uint) uint {
+	for i++; i < l.n && i%bsz != 0; i++ {
+		if l.At(i) < d {
+			return i
+		}
+	}
+	// skip blocks whose minimum is no less than d
+	for b := i / bsz; b < uint(len(l.low)); b++ {
+		if l.low[b] < d {
+			for p := b * bsz; ; p++ {
+				if l.At(p) < d {
+					return p
+				}
+			}
+		}
+	}
+	return l.n
+}
+
+// scan returns position of leftmost minimum in [i, j] by linear scan
+func (l *LCP) scan(i, j uint) uint {
+	p, v := i, l.At(i)
+	for i++; i <= j; i++ {
+		if w := l.At(i); w < v {
+			p, v = i, w
+		}
+	}
+	return p
+}
+
+func (l *LCP) minOf(p, q uint) uint {
+	if l.At(q) < l.At(p) || (l.At(q) == l.At(p) && q < p) {
+		return q
+	}
+	return p
+}
+
+// bitVector plain bit vector with select support
+type bitVector struct {
+	words []uint64
+	ranks []uint // number of ones before i-th word
+}
+
+func newBitVector(n uint) bitVector {
+	return bitVector{words: make([]uint64, n/64+1)}
+}
+
+func (b *bitVector) set(i uint) {
+	b.words[i/64] |= 1 << (i % 64)
+}
+
+// index builds rank directory, must be called after all bits are set
+func (b *bitVector) index() {
+	b.ranks = make([]uint, len(b.words))
+	ones := uint(0)
+	for i, w := range b.words {
+		b.ranks[i] = ones
+		ones += uint(bits.OnesCount64(w))
+	}
+}
+
+// select1 returns position of k-th one, k is one based
+func (b *bitVector) select1(k uint) uint {
+	w := sort.Search(len(b.ranks), func(i int) bool { return b.ranks[i] >= k }) - 1
+	x := b.words[w]
+	for j := k - b.ranks[w]; j > 1; j-- {
+		x &= x - 1
+	}
+	return uint(w)*64 + uint(bits.TrailingZeros64(x))
+}
+
+func log2(n uint) uint {
+	k := uint(0)
+	for n > 1 {
+		n >>= 1
+		k++
+	}
+	return k
+}
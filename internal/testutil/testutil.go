@@ -0,0 +1,66 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+// Package testutil generates random text of tests
+package testutil
+
+import (
+	"bytes"
+	"math/rand"
+)
+
+// Words generates n words of 1 to 8 lowercase letters from seed, separated by sep
+func Words(seed int64, n int, sep byte) []byte {
+	return WordsOf(seed, n, 8, "abcdefghijklmnopqrstuvwxyz", sep)
+}
+
+// WordsOf generates n words of 1 to maxLen letters of alphabet from seed, separated by sep
+func WordsOf(seed int64, n, maxLen int, alphabet string, sep byte) []byte {
+	r := rand.New(rand.NewSource(seed))
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteByte(sep)
+		}
+		for l := 1 + r.Intn(maxLen); l > 0; l-- {
+			buf.WriteByte(alphabet[r.Intn(len(alphabet))])
+		}
+	}
+
+	// note: no spare capacity, New may append to text in place
+	return append(make([]byte, 0, buf.Len()), buf.Bytes()...)
+}
+
+// Skewed generates n chars from seed, mostly 'a' with a rare other letter, and lines of up to 256 chars separated
+// by byte 1, so that many blocks of the index are sparse encoded
+func Skewed(seed int64, n int) []byte {
+	r := rand.New(rand.NewSource(seed))
+	buf := make([]byte, n)
+	for i, l := 0, 0; i < n; i, l = i+1, l+1 {
+		switch {
+		case l > 0 && i+1 < n && r.Intn(256) == 0:
+			buf[i], l = 1, -1
+		case r.Intn(64) == 0:
+			buf[i] = byte('b' + r.Intn(8))
+		default:
+			buf[i] = 'a'
+		}
+	}
+	return buf
+}
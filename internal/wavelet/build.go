@@ -0,0 +1,137 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package wavelet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/rleiwang/hfmi"
+)
+
+// serialized index: text len (uint64), levels (uint8), words of each level, then rows, offs and isa (uint64),
+// all little endian. zeros and rank directories are rebuilt.
+
+// maxLevels symbols are stored as a + 1, up to 2^32
+const maxLevels = 33
+
+func (w *wavelet) Bytes() []byte {
+	b := make([]byte, 9, size(w.n, len(w.m.levels)))
+	binary.LittleEndian.PutUint64(b, uint64(w.n))
+	b[8] = byte(len(w.m.levels))
+
+	put := func(v uint64) {
+		b = append(b, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint64(b[len(b)-8:], v)
+	}
+	for _, bv := range w.m.levels {
+		for _, v := range bv.words {
+			put(v)
+		}
+	}
+	for _, s := range [][]uint{w.rows, w.offs, w.isa} {
+		for _, v := range s {
+			put(uint64(v))
+		}
+	}
+	return b
+}
+
+// size returns len of Bytes() of text len n
+func size(n uint, levels int) int {
+	return 9 + 8*(levels*int((n+1)/64+1)+3*int(samples(n)))
+}
+
+// samples returns the number of sampled text offsets of text len n
+func samples(n uint) uint {
+	return (n + saRate - 1) / saRate
+}
+
+// Build restores index from bytes of Bytes(), d must pass Validate
+func Build(d []byte) hfmi.IntFMI {
+	n, levels := uint(binary.LittleEndian.Uint64(d)), int(d[8])
+	d = d[9:]
+	get := func() uint64 {
+		v := binary.LittleEndian.Uint64(d)
+		d = d[8:]
+		return v
+	}
+
+	w := &wavelet{n: n, m: &matrix{n: n + 1, levels: make([]*bitVector, levels), zeros: make([]uint, levels)}}
+	for l := range w.m.levels {
+		bv := newBitVector(w.m.n)
+		for i := range bv.words {
+			bv.words[i] = get()
+		}
+		bv.index()
+		w.m.levels[l], w.m.zeros[l] = bv, bv.rank0(w.m.n)
+	}
+	k := samples(n)
+	w.rows, w.offs, w.isa = make([]uint, k), make([]uint, k), make([]uint, k)
+	for _, s := range [][]uint{w.rows, w.offs, w.isa} {
+		for i := range s {
+			s[i] = uint(get())
+		}
+	}
+	return w
+}
+
+// Validate checks d serialized by Bytes(), so that Build doesn't panic
+func Validate(d []byte) error {
+	if len(d) < 9 {
+		return errors.New("header truncated")
+	}
+	n, levels := binary.LittleEndian.Uint64(d), int(d[8])
+	if levels > maxLevels || (levels == 0) != (n == 0) {
+		return fmt.Errorf("%d levels out of range", levels)
+	}
+	// every position takes a bit of each level
+	if n/8 > uint64(len(d)) {
+		return fmt.Errorf("text len %d out of range", n)
+	}
+	if sz := size(uint(n), levels); len(d) != sz {
+		return fmt.Errorf("%d bytes, want %d", len(d), sz)
+	}
+
+	// bits past the end of BWT must be clear, select would find them
+	words, tail := int((n+1)/64+1), (n+1)%64
+	for l := 0; l < levels; l++ {
+		last := 9 + 8*(l*words+words-1)
+		if binary.LittleEndian.Uint64(d[last:])>>tail != 0 {
+			return fmt.Errorf("level %d: bits set past the end", l)
+		}
+	}
+
+	k, s := samples(uint(n)), d[9+8*levels*words:]
+	at := func(i uint) uint64 { return binary.LittleEndian.Uint64(s[8*i:]) }
+	for i := uint(0); i < k; i++ {
+		if r := at(i); r == 0 || r > n || i > 0 && r <= at(i-1) {
+			return fmt.Errorf("sampled row %d out of order", r)
+		}
+		if o := at(k + i); o >= n || o%saRate != 0 {
+			return fmt.Errorf("sampled offset %d out of range", o)
+		}
+		if p := at(2*k + i); p > n {
+			return fmt.Errorf("sampled position %d out of range", p)
+		}
+	}
+	return nil
+}
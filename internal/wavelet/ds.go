@@ -0,0 +1,207 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package wavelet
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// bitVector plain bit vector with rank/select support
+type bitVector struct {
+	words []uint64
+	ranks []uint // number of ones before i-th word
+}
+
+func newBitVector(n uint) *bitVector {
+	// one more word, rank of n is always inside
+	return &bitVector{words: make([]uint64, n/64+1)}
+}
+
+func (b *bitVector) set(i uint) {
+	b.words[i/64] |= 1 << (i % 64)
+}
+
+func (b *bitVector) get(i uint) bool {
+	return b.words[i/64]&(1<<(i%64)) != 0
+}
+
+// index builds rank directory, must be called after all bits are set
+func (b *bitVector) index() {
+	b.ranks = make([]uint, len(b.words))
+	ones := uint(0)
+	for i, w := range b.words {
+		b.ranks[i] = ones
+		ones += uint(bits.OnesCount64(w))
+	}
+}
+
+// rank1 returns the number of ones in [0, i)
+func (b *bitVector) rank1(i uint) uint {
+	return b.ranks[i/64] + uint(bits.OnesCount64(b.words[i/64]&(1<<(i%64)-1)))
+}
+
+// rank0 returns the number of zeros in [0, i)
+func (b *bitVector) rank0(i uint) uint {
+	return i - b.rank1(i)
+}
+
+// select1 returns position of k-th one, k is one based
+func (b *bitVector) select1(k uint) uint {
+	w := sort.Search(len(b.ranks), func(i int) bool { return b.ranks[i] >= k }) - 1
+	return uint(w)*64 + nth(b.words[w], k-b.ranks[w])
+}
+
+// select0 returns position of k-th zero, k is one based
+func (b *bitVector) select0(k uint) uint {
+	w := sort.Search(len(b.ranks), func(i int) bool { return uint(i)*64-b.ranks[i] >= k }) - 1
+	return uint(w)*64 + nth(^b.words[w], k-(uint(w)*64-b.ranks[w]))
+}
+
+// nth returns position of k-th one in word w, k is one based
+func nth(w uint64, k uint) uint {
+	for ; k > 1; k-- {
+		w &= w - 1
+	}
+	return uint(bits.TrailingZeros64(w))
+}
+
+// matrix wavelet matrix of symbols, one bit vector per bit from the most significant one,
+// symbols of each level are stably partitioned by the bit, zeros first.
+type matrix struct {
+	n      uint
+	levels []*bitVector
+	zeros  []uint // number of zeros of each level
+}
+
+func newMatrix(syms []uint64, width int) *matrix {
+	m := &matrix{n: uint(len(syms)), levels: make([]*bitVector, width), zeros: make([]uint, width)}
+
+	cur, nxt := append([]uint64(nil), syms...), make([]uint64, len(syms))
+	for l := 0; l < width; l++ {
+		bv, shift := newBitVector(m.n), uint(width-1-l)
+		z := 0
+		for i, s := range cur {
+			if s>>shift&1 == 1 {
+				bv.set(uint(i))
+			} else {
+				nxt[z] = s
+				z++
+			}
+		}
+		o := z
+		for _, s := range cur {
+			if s>>shift&1 == 1 {
+				nxt[o] = s
+				o++
+			}
+		}
+		bv.index()
+		m.levels[l], m.zeros[l] = bv, uint(z)
+		cur, nxt = nxt, cur
+	}
+
+	return m
+}
+
+func (m *matrix) bit(c uint64, l int) bool {
+	return c>>uint(len(m.levels)-1-l)&1 == 1
+}
+
+// access returns symbol at position i
+func (m *matrix) access(i uint) uint64 {
+	v := uint64(0)
+	for l, bv := range m.levels {
+		v <<= 1
+		if bv.get(i) {
+			v |= 1
+			i = m.zeros[l] + bv.rank1(i)
+		} else {
+			i = bv.rank0(i)
+		}
+	}
+	return v
+}
+
+// descend returns range of c at the bottom level, which corresponds to [s, e) at the top level
+func (m *matrix) descend(c uint64, s, e uint) (uint, uint) {
+	for l, bv := range m.levels {
+		if m.bit(c, l) {
+			s, e = m.zeros[l]+bv.rank1(s), m.zeros[l]+bv.rank1(e)
+		} else {
+			s, e = bv.rank0(s), bv.rank0(e)
+		}
+	}
+	return s, e
+}
+
+// rank returns the number of c in [0, i)
+func (m *matrix) rank(c uint64, i uint) uint {
+	s, e := m.descend(c, 0, i)
+	return e - s
+}
+
+// sel returns position of r-th c, r is one based
+func (m *matrix) sel(c uint64, r uint) (uint, bool) {
+	s, e := m.descend(c, 0, m.n)
+	if r == 0 || s+r > e {
+		return 0, false
+	}
+
+	p := s + r - 1
+	for l := len(m.levels) - 1; l >= 0; l-- {
+		if m.bit(c, l) {
+			p = m.levels[l].select1(p - m.zeros[l] + 1)
+		} else {
+			p = m.levels[l].select0(p + 1)
+		}
+	}
+	return p, true
+}
+
+// less returns the number of symbols less than c in [0, i)
+func (m *matrix) less(c uint64, i uint) uint {
+	cnt, s, e := uint(0), uint(0), i
+	for l, bv := range m.levels {
+		if m.bit(c, l) {
+			cnt += bv.rank0(e) - bv.rank0(s)
+			s, e = m.zeros[l]+bv.rank1(s), m.zeros[l]+bv.rank1(e)
+		} else {
+			s, e = bv.rank0(s), bv.rank0(e)
+		}
+	}
+	return cnt
+}
+
+// quantile returns k-th smallest symbol of all, k is zero based
+func (m *matrix) quantile(k uint) uint64 {
+	v, s, e := uint64(0), uint(0), m.n
+	for l, bv := range m.levels {
+		v <<= 1
+		if z := bv.rank0(e) - bv.rank0(s); k < z {
+			s, e = bv.rank0(s), bv.rank0(e)
+		} else {
+			k -= z
+			v |= 1
+			s, e = m.zeros[l]+bv.rank1(s), m.zeros[l]+bv.rank1(e)
+		}
+	}
+	return v
+}
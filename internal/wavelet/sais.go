@@ -0,0 +1,150 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package wavelet
+
+// sais returns suffix array of t by induced sorting (SA-IS), t must end with the unique smallest 0,
+// the other symbols are in [1, k)
+func sais(t []int32, k int) []int32 {
+	n := len(t)
+	sa := make([]int32, n)
+	if n == 1 {
+		return sa
+	}
+
+	// S type if the suffix is smaller than the next one
+	stype := make([]bool, n)
+	stype[n-1] = true
+	for i := n - 2; i >= 0; i-- {
+		stype[i] = t[i] < t[i+1] || t[i] == t[i+1] && stype[i+1]
+	}
+	lms := func(i int) bool { return i > 0 && stype[i] && !stype[i-1] }
+
+	bkt := make([]int32, k)
+	buckets := func(end bool) {
+		for i := range bkt {
+			bkt[i] = 0
+		}
+		for _, c := range t {
+			bkt[c]++
+		}
+		var sum int32
+		for i, c := range bkt {
+			if sum += c; end {
+				bkt[i] = sum
+			} else {
+				bkt[i] = sum - c
+			}
+		}
+	}
+	// induce L type suffixes from left to right, then S type from right to left
+	induce := func() {
+		buckets(false)
+		for i := 0; i < n; i++ {
+			if j := sa[i] - 1; j >= 0 && !stype[j] {
+				sa[bkt[t[j]]] = j
+				bkt[t[j]]++
+			}
+		}
+		buckets(true)
+		for i := n - 1; i >= 0; i-- {
+			if j := sa[i] - 1; j >= 0 && stype[j] {
+				bkt[t[j]]--
+				sa[bkt[t[j]]] = j
+			}
+		}
+	}
+
+	// sort LMS substrings
+	for i := range sa {
+		sa[i] = -1
+	}
+	buckets(true)
+	for i := 1; i < n; i++ {
+		if lms(i) {
+			bkt[t[i]]--
+			sa[bkt[t[i]]] = int32(i)
+		}
+	}
+	induce()
+
+	// name LMS substrings, names are stored at sa[m + p/2], LMS positions are at least 2 apart
+	m := 0
+	for i := 0; i < n; i++ {
+		if lms(int(sa[i])) {
+			sa[m] = sa[i]
+			m++
+		}
+	}
+	for i := m; i < n; i++ {
+		sa[i] = -1
+	}
+	name, prev := int32(0), -1
+	for i := 0; i < m; i++ {
+		p, diff := int(sa[i]), prev < 0
+		for d := 0; !diff; d++ {
+			if p+d == n || prev+d == n || t[p+d] != t[prev+d] || stype[p+d] != stype[prev+d] {
+				diff = true
+			} else if d > 0 && lms(p+d) {
+				break
+			}
+		}
+		if diff {
+			name, prev = name+1, p
+		}
+		sa[m+p/2] = name - 1
+	}
+	j := n - 1
+	for i := n - 1; i >= m; i-- {
+		if sa[i] >= 0 {
+			sa[j] = sa[i]
+			j--
+		}
+	}
+
+	// sort LMS suffixes by the reduced string, recursively if names aren't unique
+	var sa1 []int32
+	if s1 := sa[n-m:]; int(name) < m {
+		sa1 = sais(append([]int32(nil), s1...), int(name))
+	} else {
+		sa1 = make([]int32, m)
+		for i, c := range s1 {
+			sa1[c] = int32(i)
+		}
+	}
+	pos := make([]int32, 0, m)
+	for i := 1; i < n; i++ {
+		if lms(i) {
+			pos = append(pos, int32(i))
+		}
+	}
+
+	for i := range sa {
+		sa[i] = -1
+	}
+	buckets(true)
+	for i := m - 1; i >= 0; i-- {
+		p := pos[sa1[i]]
+		bkt[t[p]]--
+		sa[bkt[t[p]]] = p
+	}
+	induce()
+
+	return sa
+}
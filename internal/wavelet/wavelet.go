@@ -0,0 +1,231 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package wavelet
+
+import (
+	"math/bits"
+	"sort"
+
+	"github.com/rleiwang/hfmi"
+)
+
+const saRate = 32 // sample rate of text offsets
+
+type wavelet struct {
+	n    uint    // text len
+	m    *matrix // L column, symbol a is stored as a + 1, 0 is the end of text
+	rows []uint  // sampled positions in BWT, ascending
+	offs []uint  // text offsets of sampled positions
+	isa  []uint  // isa[k] -> position in BWT, whose L is the symbol at text offset k * saRate
+}
+
+// New builds FMI index of integer sequence t, t must be shorter than 2^31 - 1
+func New(t []uint32) hfmi.IntFMI {
+	n := uint(len(t))
+
+	// prefixes of t are suffixes of reversed t, terminated by 0
+	r, k := reversed(t)
+	sa := sais(r, k)
+
+	// suffix r[j:] is prefix t[:n-j], L is the symbol follows the prefix
+	bwt, max := make([]uint64, n+1), uint64(0)
+	w := &wavelet{n: n, isa: make([]uint, (n+saRate-1)/saRate)}
+	for i, j := range sa {
+		if j > 0 {
+			if bwt[i] = uint64(t[n-uint(j)]) + 1; bwt[i] > max {
+				max = bwt[i]
+			}
+		}
+		if l := n - uint(j); l < n && l%saRate == 0 {
+			w.isa[l/saRate] = uint(i)
+		}
+		// F is the last symbol of prefix, at text offset n - j - 1
+		if uint(j) < n && (n-uint(j)-1)%saRate == 0 {
+			w.rows, w.offs = append(w.rows, uint(i)), append(w.offs, n-uint(j)-1)
+		}
+	}
+	w.m = newMatrix(bwt, bits.Len64(max))
+
+	return w
+}
+
+// reversed returns reversed t terminated by 0, symbols are replaced by their ranks from 1, and the alphabet size
+func reversed(t []uint32) ([]int32, int) {
+	n := len(t)
+
+	syms := append([]uint32(nil), t...)
+	sort.Slice(syms, func(i, j int) bool { return syms[i] < syms[j] })
+	k := 0
+	for i, a := range syms {
+		if i == 0 || a != syms[k-1] {
+			syms[k] = a
+			k++
+		}
+	}
+	syms = syms[:k]
+
+	r := make([]int32, n+1)
+	for i, a := range t {
+		r[n-1-i] = int32(sort.Search(k, func(j int) bool { return syms[j] >= a })) + 1
+	}
+	return r, k + 1
+}
+
+func (w *wavelet) Access(p uint) (uint32, uint, bool) {
+	if p > w.n {
+		return 0, 0, false
+	}
+	c := w.m.access(p)
+	if c == 0 {
+		return 0, 1, false
+	}
+	return uint32(c - 1), w.m.rank(c, p+1), true
+}
+
+func (w *wavelet) Select(a uint32, r uint) (uint, bool) {
+	return w.m.sel(uint64(a)+1, r)
+}
+
+func (w *wavelet) Rank(a uint32, p uint) (uint, bool) {
+	if p > w.n {
+		return 0, false
+	}
+	return w.m.rank(uint64(a)+1, p+1), true
+}
+
+func (w *wavelet) Locate(p uint) (uint32, uint, bool) {
+	if p == 0 || p > w.n {
+		// row 0 is the empty prefix
+		return 0, 1, false
+	}
+	c := w.m.quantile(p)
+	return uint32(c - 1), p - w.m.less(c, w.m.n) + 1, true
+}
+
+func (w *wavelet) Count(pat []uint32) uint {
+	rng, ok := w.Search(pat)
+	if !ok {
+		return 0
+	}
+	return rng[1] - rng[0]
+}
+
+func (w *wavelet) Search(pat []uint32) ([]uint, bool) {
+	if len(pat) == 0 {
+		return nil, false
+	}
+
+	s, e, ok := w.GetBound(pat[0])
+	if !ok {
+		return nil, false
+	}
+	for _, a := range pat[1:] {
+		if s, e = w.lf(uint64(a)+1, s, e); s == e {
+			return nil, false
+		}
+	}
+
+	return []uint{s, e}, true
+}
+
+func (w *wavelet) GetBound(a uint32) (uint, uint, bool) {
+	c := uint64(a) + 1
+	cnt := w.m.rank(c, w.m.n)
+	if cnt == 0 {
+		return 0, 0, false
+	}
+	s := w.m.less(c, w.m.n) - 1
+	return s, s + cnt, true
+}
+
+// lf returns range of c in BWT, which follows range (s, e]
+func (w *wavelet) lf(c uint64, s, e uint) (uint, uint) {
+	b := w.m.less(c, w.m.n) - 1
+	return b + w.m.rank(c, s+1), b + w.m.rank(c, e+1)
+}
+
+func (w *wavelet) Offset(p uint) (uint, bool) {
+	if p == 0 || p > w.n {
+		return 0, false
+	}
+
+	for k := uint(0); ; k++ {
+		if i := sort.Search(len(w.rows), func(i int) bool { return w.rows[i] >= p }); i < len(w.rows) && w.rows[i] == p {
+			return w.offs[i] + k, true
+		}
+
+		// F[p] precedes L[p] in text, step backward
+		a, r, ok := w.Locate(p)
+		if !ok {
+			return k - 1, true
+		}
+		if p, ok = w.Select(a, r); !ok {
+			return 0, false
+		}
+	}
+}
+
+func (w *wavelet) Occurrences(pat []uint32) []uint {
+	rng, ok := w.Search(pat)
+	if !ok {
+		return nil
+	}
+
+	offs := make([]uint, 0, rng[1]-rng[0])
+	for p := rng[0] + 1; p <= rng[1]; p++ {
+		// offset of the last symbol of occurrence
+		o, ok := w.Offset(p)
+		if !ok {
+			return nil
+		}
+		offs = append(offs, o+1-uint(len(pat)))
+	}
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+
+	return offs
+}
+
+func (w *wavelet) Extract(offset, n uint) ([]uint32, bool) {
+	if offset > w.n {
+		return nil, false
+	}
+	if offset+n > w.n {
+		n = w.n - offset
+	}
+	if n == 0 {
+		return []uint32{}, true
+	}
+
+	// walk forward from the closest sampled offset
+	p, ret := w.isa[offset/saRate], make([]uint32, 0, n)
+	for i := offset / saRate * saRate; i < offset+n; i++ {
+		c := w.m.access(p)
+		if i >= offset {
+			ret = append(ret, uint32(c-1))
+		}
+		p = w.m.less(c, w.m.n) + w.m.rank(c, p+1) - 1
+	}
+
+	return ret, true
+}
+
+func (w *wavelet) Len() uint {
+	return w.n + 1
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package wavelet
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func randomInts(seed int64, n int, sigma uint32) []uint32 {
+	r := rand.New(rand.NewSource(seed))
+	t := make([]uint32, n)
+	for i := range t {
+		t[i] = uint32(r.Int63n(int64(sigma)))
+	}
+	return t
+}
+
+// occurrences returns offsets of p in t by brute force
+func occurrences(t, p []uint32) []uint {
+	var ret []uint
+	for i := 0; i+len(p) <= len(t); i++ {
+		if reflect.DeepEqual(t[i:i+len(p)], p) {
+			ret = append(ret, uint(i))
+		}
+	}
+	return ret
+}
+
+func TestMatrix(t *testing.T) {
+	syms := make([]uint64, 1000)
+	for i, a := range randomInts(1, len(syms), 300) {
+		syms[i] = uint64(a)
+	}
+	m := newMatrix(syms, 9)
+
+	cnt := make(map[uint64]uint)
+	for i, c := range syms {
+		if got := m.access(uint(i)); got != c {
+			t.Fatalf("access(%v) = %v, want %v", i, got, c)
+		}
+		if got := m.rank(c, uint(i)); got != cnt[c] {
+			t.Fatalf("rank(%v, %v) = %v, want %v", c, i, got, cnt[c])
+		}
+		cnt[c]++
+		if got, ok := m.sel(c, cnt[c]); !ok || got != uint(i) {
+			t.Fatalf("sel(%v, %v) = %v, want %v", c, cnt[c], got, i)
+		}
+	}
+
+	sorted := append([]uint64(nil), syms...)
+	for i := 1; i < len(sorted); i++ {
+		for j := i; j > 0 && sorted[j] < sorted[j-1]; j-- {
+			sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+		}
+	}
+	for k, c := range sorted {
+		if got := m.quantile(uint(k)); got != c {
+			t.Fatalf("quantile(%v) = %v, want %v", k, got, c)
+		}
+		if k == 0 || sorted[k-1] != c {
+			if got := m.less(c, m.n); got != uint(k) {
+				t.Fatalf("less(%v) = %v, want %v", c, got, k)
+			}
+		}
+	}
+}
+
+func TestSais(t *testing.T) {
+	tests := []struct {
+		name string
+		t    []uint32
+	}{
+		{"single", []uint32{7}},
+		{"run", make([]uint32, 1000)},
+		{"periodic", []uint32{1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1, 2, 1}},
+		{"binary", randomInts(5, 3000, 2)},
+		{"small alphabet", randomInts(6, 3000, 4)},
+		{"large alphabet", randomInts(7, 3000, 1<<20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, k := reversed(tt.t)
+			want := make([]int32, len(r))
+			for i := range want {
+				want[i] = int32(i)
+			}
+			sort.Slice(want, func(i, j int) bool {
+				a, b := r[want[i]:], r[want[j]:]
+				for l := 0; l < len(a) && l < len(b); l++ {
+					if a[l] != b[l] {
+						return a[l] < b[l]
+					}
+				}
+				return len(a) < len(b)
+			})
+			if got := sais(r, k); !reflect.DeepEqual(got, want) {
+				t.Errorf("sais() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	type args struct {
+		t []uint32
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"small alphabet", args{randomInts(2, 2000, 4)}},
+		{"large alphabet", args{randomInts(3, 2000, 1<<20)}},
+		{"max symbol", args{[]uint32{math.MaxUint32, 0, math.MaxUint32, 7, math.MaxUint32, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fmi := New(tt.args.t)
+			if fmi.Len() != uint(len(tt.args.t))+1 {
+				t.Errorf("Len() = %v, want %v", fmi.Len(), len(tt.args.t)+1)
+			}
+
+			r := rand.New(rand.NewSource(4))
+			for i := 0; i < 50; i++ {
+				o, n := r.Intn(len(tt.args.t)), 1+r.Intn(3)
+				if o+n > len(tt.args.t) {
+					n = len(tt.args.t) - o
+				}
+				pat := tt.args.t[o : o+n]
+
+				want := occurrences(tt.args.t, pat)
+				if got := fmi.Count(pat); got != uint(len(want)) {
+					t.Errorf("Count(%v) = %v, want %v", pat, got, len(want))
+				}
+				if got := fmi.Occurrences(pat); !reflect.DeepEqual(got, want) {
+					t.Errorf("Occurrences(%v) = %v, want %v", pat, got, want)
+				}
+				if got, ok := fmi.Extract(uint(o), uint(n)); !ok || !reflect.DeepEqual(got, pat) {
+					t.Errorf("Extract(%v, %v) = %v, want %v", o, n, got, pat)
+				}
+			}
+
+			if got, ok := fmi.Extract(0, fmi.Len()); !ok || !reflect.DeepEqual(got, tt.args.t) {
+				t.Errorf("Extract() = %v, want %v", got, tt.args.t)
+			}
+		})
+	}
+
+	fmi := New([]uint32{1, 2, 3})
+	if _, ok := fmi.Search([]uint32{4}); ok {
+		t.Errorf("Search() = %v, want false", ok)
+	}
+	if _, ok := fmi.Search([]uint32{2, 1}); ok {
+		t.Errorf("Search() = %v, want false", ok)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	text := randomInts(8, 3000, 1000)
+	d := New(text).Bytes()
+	if err := Validate(d); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	fmi := Build(d)
+	if got, ok := fmi.Extract(0, fmi.Len()); !ok || !reflect.DeepEqual(got, text) {
+		t.Errorf("Extract() = %v, want %v", got, text)
+	}
+	pat := text[100:102]
+	if got, want := fmi.Occurrences(pat), occurrences(text, pat); !reflect.DeepEqual(got, want) {
+		t.Errorf("Occurrences(%v) = %v, want %v", pat, got, want)
+	}
+
+	if err := Validate(New(nil).Bytes()); err != nil {
+		t.Errorf("Validate() empty text error = %v", err)
+	}
+
+	corrupt := func(i int, b byte) []byte {
+		c := append([]byte(nil), d...)
+		c[i] = b
+		return c
+	}
+	// bytes of a level and of sampled rows, offsets and positions each
+	words, k := 8*(len(text)/64+1), 8*((len(text)+saRate-1)/saRate)
+	tests := []struct {
+		name string
+		d    []byte
+	}{
+		{"header", d[:8]},
+		{"truncated", d[:len(d)-1]},
+		{"levels", corrupt(8, 40)},
+		{"padding", corrupt(9+words-1, 0x80)},
+		{"rows", corrupt(len(d)-3*k, 0)},
+		{"offsets", corrupt(len(d)-2*k, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.d); err == nil {
+				t.Errorf("Validate() error = nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package query
+
+import (
+	"container/heap"
+
+	"github.com/rleiwang/hfmi"
+)
+
+// Completion continuation of prefix up to separator, and the number of occurrences
+type Completion struct {
+	Text  []byte
+	Count uint
+}
+
+// completion in progress, range (s, e] in BWT
+type partial struct {
+	text []byte
+	s, e uint
+	end  bool // reached separator
+}
+
+// -- max heap interface by count --
+
+type maxHeapByCount []*partial
+
+func (m maxHeapByCount) Len() int {
+	return len(m)
+}
+
+func (m maxHeapByCount) Less(l, r int) bool {
+	cl, cr := m[l].e-m[l].s, m[r].e-m[r].s
+	if cl == cr {
+		// completed one first, then shorter one
+		if m[l].end != m[r].end {
+			return m[l].end
+		}
+		return len(m[l].text) < len(m[r].text)
+	}
+	// count high -> low
+	return cl > cr
+}
+
+func (m maxHeapByCount) Swap(i, j int) {
+	m[i], m[j] = m[j], m[i]
+}
+
+func (m *maxHeapByCount) Push(n interface{}) {
+	*m = append(*m, n.(*partial))
+}
+
+func (m *maxHeapByCount) Pop() interface{} {
+	n := len(*m)
+	item := (*m)[n-1]
+	*m = (*m)[:n-1]
+	return item
+}
+
+// -- end heap interface --
+
+// Autocomplete returns top n most frequent completions of prefix, up to byte sep, byte 0 or 1.
+// Completions are explored in descending order of count by extending BWT range of the prefix,
+// occurrences of prefix are never extracted.
+func Autocomplete(fmi hfmi.FMI, prefix string, sep byte, n int) []Completion {
+	rng, ok := fmi.Search(prefix)
+	if !ok || n <= 0 {
+		return nil
+	}
+
+	m := maxHeapByCount{&partial{text: []byte(prefix), s: rng[0], e: rng[1]}}
+	return complete(fmi, m, sep, n, func(byte) int { return 1 })
+}
+
+// complete pops top n completions from m, width returns the number of bytes extended at once
+// for the leading byte, e.g. 1 for bytes, or the length of UTF-8 encoded rune.
+func complete(fmi hfmi.FMI, m maxHeapByCount, sep byte, n int, width func(byte) int) []Completion {
+	heap.Init(&m)
+
+	var ret []Completion
+	for len(m) > 0 && len(ret) < n {
+		p := heap.Pop(&m).(*partial)
+		if p.end {
+			ret = append(ret, Completion{p.text, p.e - p.s})
+			continue
+		}
+
+		// extend by chars following the range, cnt -> occurrences followed by separators
+		cnt := uint(0)
+		for _, c := range fmi.CharsInBound(p.s+1, p.e) {
+			s, e := narrow(fmi, c, p.s, p.e)
+			if s == e {
+				continue
+			}
+			if c == sep || c < 2 {
+				cnt += e - s
+				continue
+			}
+			text := append(append(make([]byte, 0, len(p.text)+1), p.text...), c)
+			for _, q := range expand(fmi, &partial{text: text, s: s, e: e}, width(c)-1) {
+				heap.Push(&m, q)
+			}
+		}
+		if cnt > 0 {
+			heap.Push(&m, &partial{text: p.text, e: cnt, end: true})
+		}
+	}
+
+	return ret
+}
+
+// narrow returns range of c in BWT, which follows range (s, e]
+func narrow(fmi hfmi.FMI, c byte, s, e uint) (uint, uint) {
+	b, _, _ := fmi.GetBound(c)
+	rs, _ := fmi.Rank(c, s)
+	re, _ := fmi.Rank(c, e)
+	return b + rs, b + re
+}
+
+// expand returns partials of p extended by k more bytes, byte 0 and 1 end the extension
+func expand(fmi hfmi.FMI, p *partial, k int) []*partial {
+	if k <= 0 {
+		return []*partial{p}
+	}
+
+	var ret []*partial
+	for _, c := range fmi.CharsInBound(p.s+1, p.e) {
+		if c < 2 {
+			continue
+		}
+		if s, e := narrow(fmi, c, p.s, p.e); s < e {
+			text := append(append(make([]byte, 0, len(p.text)+1), p.text...), c)
+			ret = append(ret, expand(fmi, &partial{text: text, s: s, e: e}, k-1)...)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+func TestAutocomplete(t *testing.T) {
+	text := "the cat\x01the car\x01the cat sat\x01then\x01the cart\x01a cat\x01the"
+
+	type args struct {
+		prefix string
+		sep    byte
+		n      int
+	}
+	tests := []struct {
+		name string
+		args args
+		want []Completion
+	}{
+		{"words", args{"the ca", ' ', 3}, []Completion{{[]byte("the cat"), 2}, {[]byte("the car"), 1}, {[]byte("the cart"), 1}}},
+		{"tokens", args{"th", ' ', 2}, []Completion{{[]byte("the"), 5}, {[]byte("then"), 1}}},
+		{"lines", args{"the cat", 0, 5}, []Completion{{[]byte("the cat"), 1}, {[]byte("the cat sat"), 1}}},
+		{"miss", args{"dog", ' ', 5}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fmi := ctor.New([]byte(text))
+			if got := Autocomplete(fmi, tt.args.prefix, tt.args.sep, tt.args.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Autocomplete() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package query
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rleiwang/hfmi"
+)
+
+// Term literal text, follows the previous term after a gap of Min to Max arbitrary bytes
+type Term struct {
+	Text     string
+	Min, Max uint
+}
+
+// Match occurrence of all terms, text[Offset:Offset+Len]
+type Match struct {
+	Offset uint
+	Len    uint
+}
+
+// Lit returns term follows the previous term immediately
+func Lit(text string) Term {
+	return Term{Text: text}
+}
+
+// Gap returns term follows the previous term after min to max arbitrary bytes
+func Gap(min, max uint, text string) Term {
+	return Term{Text: text, Min: min, Max: max}
+}
+
+// Count returns the number of occurrences of the first term, which are followed by all terms within gaps
+func Count(fmi hfmi.FMI, terms ...Term) uint {
+	return uint(len(Locate(fmi, terms...)))
+}
+
+// Locate returns matches in ascending order of offset, one match per occurrence of the first term,
+// with the nearest occurrence of each following term. Matches don't span documents, terms of byte 1 never match.
+// e.g. Locate(fmi, Lit("user="), Gap(1, 20, " failed"))
+//
+// note: occurrences of every term are located before the join, the cost is in the total number of occurrences
+// of all terms, not in the number of matches. Terms are counted first, so that no term is located if any term
+// doesn't occur.
+func Locate(fmi hfmi.FMI, terms ...Term) []Match {
+	if len(terms) == 0 {
+		return nil
+	}
+	for _, t := range terms {
+		if strings.IndexByte(t.Text, 1) >= 0 || fmi.Count(t.Text) == 0 {
+			return nil
+		}
+	}
+
+	// occurrences of each term, sorted by text offset
+	occs := make([][]uint, len(terms))
+	for i, t := range terms {
+		occs[i] = fmi.Occurrences(t.Text)
+	}
+	seps := newSeparators(fmi)
+
+	// backward, keep occurrences of term i which can be followed by term i+1
+	for i := len(terms) - 2; i >= 0; i-- {
+		valid := occs[i][:0]
+		for _, o := range occs[i] {
+			if _, ok := follow(occs[i+1], o+uint(len(terms[i].Text)), terms[i+1], seps); ok {
+				valid = append(valid, o)
+			}
+		}
+		if occs[i] = valid; len(valid) == 0 {
+			return nil
+		}
+	}
+
+	// forward, join the nearest occurrence of following terms
+	matches := make([]Match, len(occs[0]))
+	for j, o := range occs[0] {
+		e := o + uint(len(terms[0].Text))
+		for i, t := range terms[1:] {
+			n, _ := follow(occs[i+1], e, t, seps)
+			e = n + uint(len(t.Text))
+		}
+		matches[j] = Match{o, e - o}
+	}
+
+	return matches
+}
+
+// follow returns the first occurrence of term t in [e + t.Min, e + t.Max], the gap must not contain a separator.
+// A farther occurrence has a wider gap, so only the first occurrence is checked.
+func follow(occs []uint, e uint, t Term, seps separators) (uint, bool) {
+	i := sort.Search(len(occs), func(i int) bool { return occs[i] >= e+t.Min })
+	if i < len(occs) && occs[i] <= e+t.Max && !seps.within(e, occs[i]) {
+		return occs[i], true
+	}
+	return 0, false
+}
+
+// separators rows (s, e] of separators in F, which are in text order
+type separators struct {
+	fmi  hfmi.FMI
+	s, e uint
+}
+
+func newSeparators(fmi hfmi.FMI) separators {
+	if rng, ok := fmi.Search("\x01"); ok {
+		return separators{fmi, rng[0], rng[1]}
+	}
+	return separators{}
+}
+
+// within returns true if a separator is in text [from, to)
+func (seps separators) within(from, to uint) bool {
+	n := int(seps.e - seps.s)
+	offset := func(k int) uint {
+		off, _ := seps.fmi.Offset(seps.s + 1 + uint(k))
+		return off
+	}
+	k := sort.Search(n, func(k int) bool { return offset(k) >= from })
+	return k < n && offset(k) < to
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+func TestLocate(t *testing.T) {
+	text := strings.Join([]string{
+		"user=alice login failed",
+		"user=bob login ok",
+		"user=carol password failed twice, user=dave failed",
+		"user=eve failed",
+		"admin=root failed",
+	}, "\x01")
+
+	type args struct {
+		terms []Term
+	}
+	tests := []struct {
+		name string
+		args args
+		want []Match
+	}{
+		{"gap", args{[]Term{Lit("user="), Gap(1, 20, " failed")}},
+			[]Match{{0, 23}, {42, 26}, {76, 16}, {93, 15}}},
+		{"narrow", args{[]Term{Lit("user="), Gap(1, 4, " failed")}},
+			[]Match{{76, 16}, {93, 15}}},
+		{"three", args{[]Term{Lit("user="), Gap(0, 10, "login"), Gap(1, 1, "failed")}},
+			[]Match{{0, 23}}},
+		{"none", args{[]Term{Lit("user="), Gap(0, 3, "root")}}, nil},
+		{"missing", args{[]Term{Lit("user="), Gap(0, 3, "nobody")}}, nil},
+		{"separator", args{[]Term{Lit("user="), Gap(0, 3, "\x01")}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fmi := ctor.New([]byte(text))
+			got := Locate(fmi, tt.args.terms...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Locate() = %v, want %v", got, tt.want)
+			}
+			if c := Count(fmi, tt.args.terms...); c != uint(len(tt.want)) {
+				t.Errorf("Count() = %v, want %v", c, len(tt.want))
+			}
+		})
+	}
+
+	// the gap of a match must not cross the separator of log lines
+	fmi := ctor.New([]byte("login user=bob\x01 failed to open\x01user=eve failed"))
+	if got, want := Locate(fmi, Lit("user="), Gap(1, 20, " failed")), []Match{{31, 15}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Locate() = %v, want %v", got, want)
+	}
+}
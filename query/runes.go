@@ -0,0 +1,244 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package query
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rleiwang/hfmi"
+)
+
+// SearchRunes searches pattern with Unicode simple case folding, returns ranges in BWT (s, e]
+// of every spelling found in text, e.g. "ψυχή" matches "Ψυχή" and "ΨΥΧΉ".
+func SearchRunes(fmi hfmi.FMI, pattern string) [][]uint {
+	var ret [][]uint
+	for _, p := range fold(fmi, pattern) {
+		ret = append(ret, []uint{p.s, p.e})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i][0] < ret[j][0] })
+	return ret
+}
+
+// CountRunes returns the number of occurrences of pattern with Unicode simple case folding
+func CountRunes(fmi hfmi.FMI, pattern string) uint {
+	cnt := uint(0)
+	for _, p := range fold(fmi, pattern) {
+		cnt += p.e - p.s
+	}
+	return cnt
+}
+
+// AutocompleteRunes is Autocomplete, which matches prefix with Unicode simple case folding
+// and extends completions by whole runes.
+func AutocompleteRunes(fmi hfmi.FMI, prefix string, sep byte, n int) []Completion {
+	m := fold(fmi, prefix)
+	if len(m) == 0 || n <= 0 {
+		return nil
+	}
+	return complete(fmi, m, sep, n, width)
+}
+
+// RunesInBound returns distinct runes starting at positions between bound [start, end] in BWT
+func RunesInBound(fmi hfmi.FMI, start, end uint) []rune {
+	if end >= fmi.Len() {
+		end = fmi.Len() - 1
+	}
+	if start > end {
+		return nil
+	}
+
+	set := make(map[rune]bool)
+	if start == 0 {
+		// range is (s, e], row 0 can't be excluded
+		for _, r := range ExtractRunes(fmi, 0, 1) {
+			set[r] = true
+		}
+		start = 1
+	}
+	if start <= end {
+		for _, c := range fmi.CharsInBound(start, end) {
+			if c < 2 || !utf8.RuneStart(c) {
+				continue
+			}
+			if s, e := narrow(fmi, c, start-1, end); s < e {
+				for _, p := range expand(fmi, &partial{text: []byte{c}, s: s, e: e}, width(c)-1) {
+					r, _ := utf8.DecodeRune(p.text)
+					set[r] = true
+				}
+			}
+		}
+	}
+
+	ret := make([]rune, 0, len(set))
+	for r := range set {
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+// ExtractRange is FMI.ExtractRange, which never splits a code point. from is moved backward to
+// the leading byte of its rune, and extraction continues after to until the last rune completes.
+func ExtractRange(fmi hfmi.FMI, from, to uint) ([]byte, bool) {
+	if from >= fmi.Len() || to >= fmi.Len() {
+		return nil, false
+	}
+
+	var buf []byte
+	done := false
+	for p := leading(fmi, from); ; {
+		c, r, _ := fmi.Access(p)
+		if c < 2 || (done && utf8.RuneStart(c)) {
+			return buf, true
+		}
+		buf = append(buf, c)
+		done = done || p == to
+
+		b, _, _ := fmi.GetBound(c)
+		p = b + r
+	}
+}
+
+// ExtractRunes returns up to n runes from the rune at position p in BWT, until byte 0 or 1
+func ExtractRunes(fmi hfmi.FMI, p uint, n int) []rune {
+	if p >= fmi.Len() {
+		return nil
+	}
+
+	var ret []rune
+	var buf []byte
+	for p = leading(fmi, p); len(ret) < n; {
+		c, r, _ := fmi.Access(p)
+		if c < 2 || (len(buf) > 0 && utf8.RuneStart(c)) {
+			if len(buf) > 0 {
+				rn, _ := utf8.DecodeRune(buf)
+				ret, buf = append(ret, rn), buf[:0]
+			}
+			if c < 2 {
+				break
+			}
+		}
+		buf = append(buf, c)
+
+		b, _, _ := fmi.GetBound(c)
+		p = b + r
+	}
+	return ret
+}
+
+// leading returns position in BWT of the leading byte of the rune at p
+func leading(fmi hfmi.FMI, p uint) uint {
+	for i := 1; i < utf8.UTFMax; i++ {
+		if c, _, _ := fmi.Access(p); utf8.RuneStart(c) {
+			break
+		}
+		// F[p] precedes L[p] in text
+		b, r, ok := fmi.Locate(p)
+		if !ok || b < utf8.RuneSelf {
+			break
+		}
+		if p, ok = fmi.Select(b, r); !ok {
+			break
+		}
+	}
+	return p
+}
+
+// fold returns ranges in BWT of spellings of pattern found in text
+func fold(fmi hfmi.FMI, pattern string) []*partial {
+	if len(pattern) == 0 {
+		return nil
+	}
+
+	var ps []*partial
+	for i, w := 0, 0; i < len(pattern); i += w {
+		var alts []string
+		var r rune
+		if r, w = utf8.DecodeRuneInString(pattern[i:]); r == utf8.RuneError && w == 1 {
+			alts = []string{pattern[i : i+1]}
+		} else {
+			for f := r; ; {
+				alts = append(alts, string(f))
+				if f = unicode.SimpleFold(f); f == r {
+					break
+				}
+			}
+		}
+
+		var next []*partial
+		for _, a := range alts {
+			if i == 0 {
+				if p, ok := first(fmi, a); ok {
+					next = append(next, p)
+				}
+				continue
+			}
+			for _, p := range ps {
+				if q, ok := extend(fmi, p, a); ok {
+					next = append(next, q)
+				}
+			}
+		}
+		if ps = next; len(ps) == 0 {
+			return nil
+		}
+	}
+	return ps
+}
+
+// first returns range of text, which starts a pattern
+func first(fmi hfmi.FMI, text string) (*partial, bool) {
+	s, e, ok := fmi.GetBound(text[0])
+	if !ok || s == e {
+		return nil, false
+	}
+	return extend(fmi, &partial{text: []byte{text[0]}, s: s, e: e}, text[1:])
+}
+
+// extend returns range of p followed by text
+func extend(fmi hfmi.FMI, p *partial, text string) (*partial, bool) {
+	s, e := p.s, p.e
+	for i := 0; i < len(text); i++ {
+		if _, _, ok := fmi.GetBound(text[i]); !ok {
+			return nil, false
+		}
+		if s, e = narrow(fmi, text[i], s, e); s == e {
+			return nil, false
+		}
+	}
+	return &partial{text: append(append(make([]byte, 0, len(p.text)+len(text)), p.text...), text...), s: s, e: e}, true
+}
+
+// width returns the length of UTF-8 encoded rune, which starts with byte c
+func width(c byte) int {
+	switch {
+	case c >= 0xF8:
+		return 1
+	case c >= 0xF0:
+		return 4
+	case c >= 0xE0:
+		return 3
+	case c >= 0xC0:
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+const greek = "Ψυχή μου\x01ψυχή\x01ΨΥΧΉ και σώμα\x01straße\x01STRAẞE\x01日本語の文章\x01日本"
+
+func TestSearchRunes(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    uint
+	}{
+		{"greek", "ψυχή", 3},
+		{"upper", "ΨΥΧΉ", 3},
+		{"sharp s", "STRAßE", 2},
+		{"cjk", "日本", 2},
+		{"miss", "ψυχη", 0},
+	}
+	fmi := ctor.New([]byte(greek))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CountRunes(fmi, tt.pattern); got != tt.want {
+				t.Errorf("CountRunes() = %v, want %v", got, tt.want)
+			}
+			cnt := uint(0)
+			for _, rng := range SearchRunes(fmi, tt.pattern) {
+				cnt += rng[1] - rng[0]
+			}
+			if cnt != tt.want {
+				t.Errorf("SearchRunes() = %v occurrences, want %v", cnt, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractRange(t *testing.T) {
+	fmi := ctor.New([]byte(greek))
+	for p := uint(0); p < fmi.Len(); p++ {
+		buf, ok := ExtractRange(fmi, p, p)
+		if !ok || (len(buf) > 0 && (!utf8.Valid(buf) || utf8.RuneCount(buf) != 1)) {
+			t.Errorf("ExtractRange(%v) = %q, want one rune", p, buf)
+		}
+
+		rs := ExtractRunes(fmi, p, 3)
+		if len(rs) > 0 && !strings.Contains(greek, string(rs)) {
+			t.Errorf("ExtractRunes(%v) = %q, not in text", p, string(rs))
+		}
+	}
+}
+
+func TestRunesInBound(t *testing.T) {
+	fmi := ctor.New([]byte(greek))
+	rng := SearchRunes(fmi, "日")
+	if got, want := RunesInBound(fmi, rng[0][0]+1, rng[0][1]), []rune("本"); !reflect.DeepEqual(got, want) {
+		t.Errorf("RunesInBound() = %q, want %q", string(got), string(want))
+	}
+
+	var all []rune
+	for _, r := range strings.ReplaceAll(greek, "\x01", "") {
+		if !strings.ContainsRune(string(all), r) {
+			all = append(all, r)
+		}
+	}
+	if got := RunesInBound(fmi, 0, fmi.Len()-1); len(got) != len(all) {
+		t.Errorf("RunesInBound() = %q, want %q", string(got), string(all))
+	}
+}
+
+func TestAutocompleteRunes(t *testing.T) {
+	fmi := ctor.New([]byte(greek))
+	want := []Completion{{[]byte("ψυχή"), 1}, {[]byte("Ψυχή"), 1}, {[]byte("ΨΥΧΉ"), 1}}
+	got := AutocompleteRunes(fmi, "ψυ", ' ', 5)
+	if len(got) != len(want) {
+		t.Fatalf("AutocompleteRunes() = %q, want %q", got, want)
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			found = found || reflect.DeepEqual(g, w)
+		}
+		if !found {
+			t.Errorf("AutocompleteRunes() = %q, missing %q", got, w)
+		}
+	}
+}
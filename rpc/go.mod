@@ -0,0 +1,17 @@
+module github.com/rleiwang/hfmi/rpc
+
+go 1.25.0
+
+require (
+	github.com/rleiwang/hfmi v0.0.0-20261018203043-4ed8023fea36
+	google.golang.org/grpc v1.79.3
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	github.com/rleiwang/sa v1.0.0 // indirect
+	golang.org/x/net v0.48.0 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+	golang.org/x/text v0.32.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20251202230838-ff82c1b0f217 // indirect
+)
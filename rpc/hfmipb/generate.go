@@ -17,16 +17,7 @@
  * expansion.
  */
 
-package hybrid
+// Package hfmipb protobuf messages and gRPC service of FMI queries
+package hfmipb
 
-import (
-	"os"
-	"testing"
-
-	"github.com/rleiwang/hfmi/internal"
-)
-
-func TestMain(m *testing.M) {
-	internal.InitSegmentCache(25)
-	os.Exit(m.Run())
-}
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative hfmi.proto
@@ -0,0 +1,576 @@
+//
+// Copyright 2020 Rock Lei Wang
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Package parser declares an expression parser with support for macro
+// expansion.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: hfmi.proto
+
+package hfmipb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CountRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         string                 `protobuf:"bytes,1,opt,name=index,proto3" json:"index,omitempty"`
+	Pattern       []byte                 `protobuf:"bytes,2,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountRequest) Reset() {
+	*x = CountRequest{}
+	mi := &file_hfmi_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountRequest) ProtoMessage() {}
+
+func (x *CountRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_hfmi_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountRequest.ProtoReflect.Descriptor instead.
+func (*CountRequest) Descriptor() ([]byte, []int) {
+	return file_hfmi_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CountRequest) GetIndex() string {
+	if x != nil {
+		return x.Index
+	}
+	return ""
+}
+
+func (x *CountRequest) GetPattern() []byte {
+	if x != nil {
+		return x.Pattern
+	}
+	return nil
+}
+
+type CountResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Count         uint64                 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CountResponse) Reset() {
+	*x = CountResponse{}
+	mi := &file_hfmi_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CountResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CountResponse) ProtoMessage() {}
+
+func (x *CountResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_hfmi_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CountResponse.ProtoReflect.Descriptor instead.
+func (*CountResponse) Descriptor() ([]byte, []int) {
+	return file_hfmi_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CountResponse) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+type SearchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         string                 `protobuf:"bytes,1,opt,name=index,proto3" json:"index,omitempty"`
+	Pattern       []byte                 `protobuf:"bytes,2,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_hfmi_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_hfmi_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_hfmi_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SearchRequest) GetIndex() string {
+	if x != nil {
+		return x.Index
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetPattern() []byte {
+	if x != nil {
+		return x.Pattern
+	}
+	return nil
+}
+
+type SearchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Found         bool                   `protobuf:"varint,1,opt,name=found,proto3" json:"found,omitempty"`
+	Start         uint64                 `protobuf:"varint,2,opt,name=start,proto3" json:"start,omitempty"`
+	End           uint64                 `protobuf:"varint,3,opt,name=end,proto3" json:"end,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_hfmi_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_hfmi_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_hfmi_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SearchResponse) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *SearchResponse) GetStart() uint64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetEnd() uint64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+type ExtractRangeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         string                 `protobuf:"bytes,1,opt,name=index,proto3" json:"index,omitempty"`
+	From          uint64                 `protobuf:"varint,2,opt,name=from,proto3" json:"from,omitempty"`
+	To            uint64                 `protobuf:"varint,3,opt,name=to,proto3" json:"to,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtractRangeRequest) Reset() {
+	*x = ExtractRangeRequest{}
+	mi := &file_hfmi_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractRangeRequest) ProtoMessage() {}
+
+func (x *ExtractRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_hfmi_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractRangeRequest.ProtoReflect.Descriptor instead.
+func (*ExtractRangeRequest) Descriptor() ([]byte, []int) {
+	return file_hfmi_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ExtractRangeRequest) GetIndex() string {
+	if x != nil {
+		return x.Index
+	}
+	return ""
+}
+
+func (x *ExtractRangeRequest) GetFrom() uint64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *ExtractRangeRequest) GetTo() uint64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+type ExtractRangeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          []byte                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtractRangeResponse) Reset() {
+	*x = ExtractRangeResponse{}
+	mi := &file_hfmi_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractRangeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractRangeResponse) ProtoMessage() {}
+
+func (x *ExtractRangeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_hfmi_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractRangeResponse.ProtoReflect.Descriptor instead.
+func (*ExtractRangeResponse) Descriptor() ([]byte, []int) {
+	return file_hfmi_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ExtractRangeResponse) GetText() []byte {
+	if x != nil {
+		return x.Text
+	}
+	return nil
+}
+
+type ExtractAllFieldsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Index string                 `protobuf:"bytes,1,opt,name=index,proto3" json:"index,omitempty"`
+	// separator byte, must be 1
+	Sep        uint32 `protobuf:"varint,2,opt,name=sep,proto3" json:"sep,omitempty"`
+	FieldCount uint64 `protobuf:"varint,3,opt,name=field_count,json=fieldCount,proto3" json:"field_count,omitempty"`
+	// the first row, for resuming a broken stream
+	Offset        uint64 `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExtractAllFieldsRequest) Reset() {
+	*x = ExtractAllFieldsRequest{}
+	mi := &file_hfmi_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExtractAllFieldsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractAllFieldsRequest) ProtoMessage() {}
+
+func (x *ExtractAllFieldsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_hfmi_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractAllFieldsRequest.ProtoReflect.Descriptor instead.
+func (*ExtractAllFieldsRequest) Descriptor() ([]byte, []int) {
+	return file_hfmi_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ExtractAllFieldsRequest) GetIndex() string {
+	if x != nil {
+		return x.Index
+	}
+	return ""
+}
+
+func (x *ExtractAllFieldsRequest) GetSep() uint32 {
+	if x != nil {
+		return x.Sep
+	}
+	return 0
+}
+
+func (x *ExtractAllFieldsRequest) GetFieldCount() uint64 {
+	if x != nil {
+		return x.FieldCount
+	}
+	return 0
+}
+
+func (x *ExtractAllFieldsRequest) GetOffset() uint64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type Row struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Row           uint64                 `protobuf:"varint,1,opt,name=row,proto3" json:"row,omitempty"`
+	Fields        [][]byte               `protobuf:"bytes,2,rep,name=fields,proto3" json:"fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Row) Reset() {
+	*x = Row{}
+	mi := &file_hfmi_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Row) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Row) ProtoMessage() {}
+
+func (x *Row) ProtoReflect() protoreflect.Message {
+	mi := &file_hfmi_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
+	return file_hfmi_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Row) GetRow() uint64 {
+	if x != nil {
+		return x.Row
+	}
+	return 0
+}
+
+func (x *Row) GetFields() [][]byte {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+var File_hfmi_proto protoreflect.FileDescriptor
+
+const file_hfmi_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"hfmi.proto\x12\ahfmi.v1\">\n" +
+	"\fCountRequest\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\tR\x05index\x12\x18\n" +
+	"\apattern\x18\x02 \x01(\fR\apattern\"%\n" +
+	"\rCountResponse\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x04R\x05count\"?\n" +
+	"\rSearchRequest\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\tR\x05index\x12\x18\n" +
+	"\apattern\x18\x02 \x01(\fR\apattern\"N\n" +
+	"\x0eSearchResponse\x12\x14\n" +
+	"\x05found\x18\x01 \x01(\bR\x05found\x12\x14\n" +
+	"\x05start\x18\x02 \x01(\x04R\x05start\x12\x10\n" +
+	"\x03end\x18\x03 \x01(\x04R\x03end\"O\n" +
+	"\x13ExtractRangeRequest\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\tR\x05index\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\x04R\x04from\x12\x0e\n" +
+	"\x02to\x18\x03 \x01(\x04R\x02to\"*\n" +
+	"\x14ExtractRangeResponse\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\fR\x04text\"z\n" +
+	"\x17ExtractAllFieldsRequest\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\tR\x05index\x12\x10\n" +
+	"\x03sep\x18\x02 \x01(\rR\x03sep\x12\x1f\n" +
+	"\vfield_count\x18\x03 \x01(\x04R\n" +
+	"fieldCount\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x04R\x06offset\"/\n" +
+	"\x03Row\x12\x10\n" +
+	"\x03row\x18\x01 \x01(\x04R\x03row\x12\x16\n" +
+	"\x06fields\x18\x02 \x03(\fR\x06fields2\x8b\x02\n" +
+	"\x03FMI\x126\n" +
+	"\x05Count\x12\x15.hfmi.v1.CountRequest\x1a\x16.hfmi.v1.CountResponse\x129\n" +
+	"\x06Search\x12\x16.hfmi.v1.SearchRequest\x1a\x17.hfmi.v1.SearchResponse\x12K\n" +
+	"\fExtractRange\x12\x1c.hfmi.v1.ExtractRangeRequest\x1a\x1d.hfmi.v1.ExtractRangeResponse\x12D\n" +
+	"\x10ExtractAllFields\x12 .hfmi.v1.ExtractAllFieldsRequest\x1a\f.hfmi.v1.Row0\x01B%Z#github.com/rleiwang/hfmi/rpc/hfmipbb\x06proto3"
+
+var (
+	file_hfmi_proto_rawDescOnce sync.Once
+	file_hfmi_proto_rawDescData []byte
+)
+
+func file_hfmi_proto_rawDescGZIP() []byte {
+	file_hfmi_proto_rawDescOnce.Do(func() {
+		file_hfmi_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_hfmi_proto_rawDesc), len(file_hfmi_proto_rawDesc)))
+	})
+	return file_hfmi_proto_rawDescData
+}
+
+var file_hfmi_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_hfmi_proto_goTypes = []any{
+	(*CountRequest)(nil),            // 0: hfmi.v1.CountRequest
+	(*CountResponse)(nil),           // 1: hfmi.v1.CountResponse
+	(*SearchRequest)(nil),           // 2: hfmi.v1.SearchRequest
+	(*SearchResponse)(nil),          // 3: hfmi.v1.SearchResponse
+	(*ExtractRangeRequest)(nil),     // 4: hfmi.v1.ExtractRangeRequest
+	(*ExtractRangeResponse)(nil),    // 5: hfmi.v1.ExtractRangeResponse
+	(*ExtractAllFieldsRequest)(nil), // 6: hfmi.v1.ExtractAllFieldsRequest
+	(*Row)(nil),                     // 7: hfmi.v1.Row
+}
+var file_hfmi_proto_depIdxs = []int32{
+	0, // 0: hfmi.v1.FMI.Count:input_type -> hfmi.v1.CountRequest
+	2, // 1: hfmi.v1.FMI.Search:input_type -> hfmi.v1.SearchRequest
+	4, // 2: hfmi.v1.FMI.ExtractRange:input_type -> hfmi.v1.ExtractRangeRequest
+	6, // 3: hfmi.v1.FMI.ExtractAllFields:input_type -> hfmi.v1.ExtractAllFieldsRequest
+	1, // 4: hfmi.v1.FMI.Count:output_type -> hfmi.v1.CountResponse
+	3, // 5: hfmi.v1.FMI.Search:output_type -> hfmi.v1.SearchResponse
+	5, // 6: hfmi.v1.FMI.ExtractRange:output_type -> hfmi.v1.ExtractRangeResponse
+	7, // 7: hfmi.v1.FMI.ExtractAllFields:output_type -> hfmi.v1.Row
+	4, // [4:8] is the sub-list for method output_type
+	0, // [0:4] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_hfmi_proto_init() }
+func file_hfmi_proto_init() {
+	if File_hfmi_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_hfmi_proto_rawDesc), len(file_hfmi_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_hfmi_proto_goTypes,
+		DependencyIndexes: file_hfmi_proto_depIdxs,
+		MessageInfos:      file_hfmi_proto_msgTypes,
+	}.Build()
+	File_hfmi_proto = out.File
+	file_hfmi_proto_goTypes = nil
+	file_hfmi_proto_depIdxs = nil
+}
@@ -0,0 +1,269 @@
+//
+// Copyright 2020 Rock Lei Wang
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// Package parser declares an expression parser with support for macro
+// expansion.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: hfmi.proto
+
+package hfmipb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	FMI_Count_FullMethodName            = "/hfmi.v1.FMI/Count"
+	FMI_Search_FullMethodName           = "/hfmi.v1.FMI/Search"
+	FMI_ExtractRange_FullMethodName     = "/hfmi.v1.FMI/ExtractRange"
+	FMI_ExtractAllFields_FullMethodName = "/hfmi.v1.FMI/ExtractAllFields"
+)
+
+// FMIClient is the client API for FMI service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// FMI queries of indexes served by name
+type FMIClient interface {
+	// Count the number of pattern occurrence
+	Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*CountResponse, error)
+	// Search search pattern, return range in BWT (start, end]
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// ExtractRange returns text between BWT positions from and to, or byte 0/1, which ever comes first
+	ExtractRange(ctx context.Context, in *ExtractRangeRequest, opts ...grpc.CallOption) (*ExtractRangeResponse, error)
+	// ExtractAllFields streams rows, each row has field_count fields separated by sep
+	ExtractAllFields(ctx context.Context, in *ExtractAllFieldsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Row], error)
+}
+
+type fMIClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFMIClient(cc grpc.ClientConnInterface) FMIClient {
+	return &fMIClient{cc}
+}
+
+func (c *fMIClient) Count(ctx context.Context, in *CountRequest, opts ...grpc.CallOption) (*CountResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CountResponse)
+	err := c.cc.Invoke(ctx, FMI_Count_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fMIClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, FMI_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fMIClient) ExtractRange(ctx context.Context, in *ExtractRangeRequest, opts ...grpc.CallOption) (*ExtractRangeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ExtractRangeResponse)
+	err := c.cc.Invoke(ctx, FMI_ExtractRange_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fMIClient) ExtractAllFields(ctx context.Context, in *ExtractAllFieldsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Row], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FMI_ServiceDesc.Streams[0], FMI_ExtractAllFields_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExtractAllFieldsRequest, Row]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FMI_ExtractAllFieldsClient = grpc.ServerStreamingClient[Row]
+
+// FMIServer is the server API for FMI service.
+// All implementations must embed UnimplementedFMIServer
+// for forward compatibility.
+//
+// FMI queries of indexes served by name
+type FMIServer interface {
+	// Count the number of pattern occurrence
+	Count(context.Context, *CountRequest) (*CountResponse, error)
+	// Search search pattern, return range in BWT (start, end]
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// ExtractRange returns text between BWT positions from and to, or byte 0/1, which ever comes first
+	ExtractRange(context.Context, *ExtractRangeRequest) (*ExtractRangeResponse, error)
+	// ExtractAllFields streams rows, each row has field_count fields separated by sep
+	ExtractAllFields(*ExtractAllFieldsRequest, grpc.ServerStreamingServer[Row]) error
+	mustEmbedUnimplementedFMIServer()
+}
+
+// UnimplementedFMIServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFMIServer struct{}
+
+func (UnimplementedFMIServer) Count(context.Context, *CountRequest) (*CountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Count not implemented")
+}
+func (UnimplementedFMIServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedFMIServer) ExtractRange(context.Context, *ExtractRangeRequest) (*ExtractRangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExtractRange not implemented")
+}
+func (UnimplementedFMIServer) ExtractAllFields(*ExtractAllFieldsRequest, grpc.ServerStreamingServer[Row]) error {
+	return status.Errorf(codes.Unimplemented, "method ExtractAllFields not implemented")
+}
+func (UnimplementedFMIServer) mustEmbedUnimplementedFMIServer() {}
+func (UnimplementedFMIServer) testEmbeddedByValue()             {}
+
+// UnsafeFMIServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FMIServer will
+// result in compilation errors.
+type UnsafeFMIServer interface {
+	mustEmbedUnimplementedFMIServer()
+}
+
+func RegisterFMIServer(s grpc.ServiceRegistrar, srv FMIServer) {
+	// If the following call pancis, it indicates UnimplementedFMIServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FMI_ServiceDesc, srv)
+}
+
+func _FMI_Count_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FMIServer).Count(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FMI_Count_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FMIServer).Count(ctx, req.(*CountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FMI_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FMIServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FMI_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FMIServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FMI_ExtractRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtractRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FMIServer).ExtractRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FMI_ExtractRange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FMIServer).ExtractRange(ctx, req.(*ExtractRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FMI_ExtractAllFields_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExtractAllFieldsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FMIServer).ExtractAllFields(m, &grpc.GenericServerStream[ExtractAllFieldsRequest, Row]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FMI_ExtractAllFieldsServer = grpc.ServerStreamingServer[Row]
+
+// FMI_ServiceDesc is the grpc.ServiceDesc for FMI service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FMI_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hfmi.v1.FMI",
+	HandlerType: (*FMIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Count",
+			Handler:    _FMI_Count_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _FMI_Search_Handler,
+		},
+		{
+			MethodName: "ExtractRange",
+			Handler:    _FMI_ExtractRange_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExtractAllFields",
+			Handler:       _FMI_ExtractAllFields_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "hfmi.proto",
+}
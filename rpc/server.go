@@ -0,0 +1,123 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+// Package rpc serves FMI queries over gRPC, see hfmipb/hfmi.proto
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/rpc/hfmipb"
+)
+
+// Server implements hfmipb.FMIServer of indexes keyed by name
+type Server struct {
+	hfmipb.UnimplementedFMIServer
+	indexes map[string]hfmi.FMI
+}
+
+// NewServer returns server of indexes keyed by name, indexes must not be modified afterwards
+func NewServer(indexes map[string]hfmi.FMI) *Server {
+	return &Server{indexes: indexes}
+}
+
+func (s *Server) Count(ctx context.Context, req *hfmipb.CountRequest) (*hfmipb.CountResponse, error) {
+	fmi, err := s.index(req.GetIndex())
+	if err != nil {
+		return nil, err
+	}
+	return &hfmipb.CountResponse{Count: uint64(fmi.Count(string(req.GetPattern())))}, nil
+}
+
+func (s *Server) Search(ctx context.Context, req *hfmipb.SearchRequest) (*hfmipb.SearchResponse, error) {
+	fmi, err := s.index(req.GetIndex())
+	if err != nil {
+		return nil, err
+	}
+
+	rng, ok := fmi.Search(string(req.GetPattern()))
+	if !ok {
+		return &hfmipb.SearchResponse{}, nil
+	}
+	return &hfmipb.SearchResponse{Found: true, Start: uint64(rng[0]), End: uint64(rng[1])}, nil
+}
+
+func (s *Server) ExtractRange(ctx context.Context, req *hfmipb.ExtractRangeRequest) (*hfmipb.ExtractRangeResponse, error) {
+	fmi, err := s.index(req.GetIndex())
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := req.GetFrom(), req.GetTo()
+	if from >= uint64(fmi.Len()) || to >= uint64(fmi.Len()) {
+		return nil, status.Errorf(codes.OutOfRange, "position out of range [0, %d)", fmi.Len())
+	}
+
+	text, _ := fmi.ExtractRange(uint(from), uint(to))
+	return &hfmipb.ExtractRangeResponse{Text: text}, nil
+}
+
+func (s *Server) ExtractAllFields(req *hfmipb.ExtractAllFieldsRequest, stream hfmipb.FMI_ExtractAllFieldsServer) error {
+	fmi, err := s.index(req.GetIndex())
+	if err != nil {
+		return err
+	}
+
+	sep, fc := req.GetSep(), req.GetFieldCount()
+	if sep == 0 {
+		sep = 1
+	}
+	if sep != 1 || fc == 0 {
+		return status.Errorf(codes.InvalidArgument, "sep %d, field count %d: sep must be 1, field count must be positive", sep, fc)
+	}
+
+	it := fmi.Rows(byte(sep), uint(fc))
+	if off := req.GetOffset(); off > 0 && !it.Seek(uint(off)) {
+		return status.Errorf(codes.OutOfRange, "row %d out of range", off)
+	}
+
+	// fields are reused by iterator, they are marshaled by Send before Next
+	for it.Next() {
+		if err = stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+		if err = stream.Send(&hfmipb.Row{Row: uint64(it.Row()), Fields: it.Fields()}); err != nil {
+			return err
+		}
+	}
+
+	switch err = it.Err(); err {
+	case nil:
+		return nil
+	case hfmi.ErrSeparator, hfmi.ErrRowSeparator:
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (s *Server) index(name string) (hfmi.FMI, error) {
+	if fmi, ok := s.indexes[name]; ok {
+		return fmi, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "index %s", name)
+}
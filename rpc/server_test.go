@@ -0,0 +1,118 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/ctor"
+	"github.com/rleiwang/hfmi/rpc/hfmipb"
+)
+
+// dial returns client of server over local bufconn listener
+func dial(t *testing.T, s *Server) hfmipb.FMIClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	hfmipb.RegisterFMIServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return hfmipb.NewFMIClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	text := "ab\x01cd\x01ef\x01ab\x01ij\x01kl"
+	fmi := ctor.New([]byte(text))
+	client := dial(t, NewServer(map[string]hfmi.FMI{"t": fmi}))
+	ctx := context.Background()
+
+	cnt, err := client.Count(ctx, &hfmipb.CountRequest{Index: "t", Pattern: []byte("ab")})
+	if err != nil || cnt.GetCount() != 2 {
+		t.Errorf("Count() = %v, %v, want 2", cnt, err)
+	}
+
+	rng, _ := fmi.Search("ab")
+	sr, err := client.Search(ctx, &hfmipb.SearchRequest{Index: "t", Pattern: []byte("ab")})
+	if err != nil || !sr.GetFound() || sr.GetStart() != uint64(rng[0]) || sr.GetEnd() != uint64(rng[1]) {
+		t.Errorf("Search() = %v, %v, want %v", sr, err, rng)
+	}
+
+	er, err := client.ExtractRange(ctx, &hfmipb.ExtractRangeRequest{Index: "t", From: 0, To: 1})
+	if want, _ := fmi.ExtractRange(0, 1); err != nil || !reflect.DeepEqual(er.GetText(), want) {
+		t.Errorf("ExtractRange() = %q, %v, want %q", er.GetText(), err, want)
+	}
+
+	stream, err := client.ExtractAllFields(ctx, &hfmipb.ExtractAllFieldsRequest{Index: "t", FieldCount: 2, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows [][][]byte
+	for {
+		row, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row.GetFields())
+	}
+	if want := [][][]byte{{[]byte("ef"), []byte("ab")}, {[]byte("ij"), []byte("kl")}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("ExtractAllFields() = %q, want %q", rows, want)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	client := dial(t, NewServer(map[string]hfmi.FMI{"t": ctor.New([]byte("ab\x01cd"))}))
+	ctx := context.Background()
+
+	if _, err := client.Count(ctx, &hfmipb.CountRequest{Index: "x"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Count() error = %v, want %v", err, codes.NotFound)
+	}
+	if _, err := client.ExtractRange(ctx, &hfmipb.ExtractRangeRequest{Index: "t", From: 100}); status.Code(err) != codes.OutOfRange {
+		t.Errorf("ExtractRange() error = %v, want %v", err, codes.OutOfRange)
+	}
+
+	stream, err := client.ExtractAllFields(ctx, &hfmipb.ExtractAllFieldsRequest{Index: "t", Sep: ',', FieldCount: 1})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ExtractAllFields() error = %v, want %v", err, codes.InvalidArgument)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package shard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/rleiwang/hfmi"
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+const version = 1
+
+// ErrManifest manifest is invalid
+var ErrManifest = errors.New("shard: invalid manifest")
+
+// Manifest describes shards, files are relative to the manifest file
+type Manifest struct {
+	Version int     `json:"version"`
+	Shards  []Entry `json:"shards"`
+}
+
+// Entry describes a shard, Len is length of the index (FMI.Len), i.e. text length plus the sentinel
+type Entry struct {
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Len       uint   `json:"len"`
+	Documents uint   `json:"documents"`
+}
+
+// ReadManifest reads manifest file
+func ReadManifest(file string) (Manifest, error) {
+	var m Manifest
+
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return m, err
+	}
+	if err = json.Unmarshal(d, &m); err != nil {
+		return m, fmt.Errorf("%w: %s: %v", ErrManifest, file, err)
+	}
+	if m.Version != version {
+		return m, fmt.Errorf("%w: %s: unsupported version %d", ErrManifest, file, m.Version)
+	}
+
+	names := make(map[string]bool, len(m.Shards))
+	for _, e := range m.Shards {
+		if e.Name == "" || e.File == "" || names[e.Name] {
+			return m, fmt.Errorf("%w: %s: shard %q", ErrManifest, file, e.Name)
+		}
+		names[e.Name] = true
+	}
+
+	return m, nil
+}
+
+// WriteManifest writes manifest file
+func WriteManifest(file string, m Manifest) error {
+	m.Version = version
+	d, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(d, '\n'), 0644)
+}
+
+// Open loads shards described by manifest file
+func Open(manifest string) (*Sharded, error) {
+	m, err := ReadManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(manifest)
+	shards := make([]Shard, len(m.Shards))
+	for i, e := range m.Shards {
+		file := e.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+
+		var fmi hfmi.FMI
+		if fmi, err = ctor.ReadFile(file); err != nil {
+			return nil, err
+		}
+		if e.Len != 0 && e.Len != fmi.Len() {
+			return nil, fmt.Errorf("%w: shard %s: len %d, index file has %d", ErrManifest, e.Name, e.Len, fmi.Len())
+		}
+		shards[i] = Shard{e.Name, fmi}
+	}
+
+	return New(shards...), nil
+}
+
+// Save writes every shard to index file named after the shard in the directory of manifest,
+// and the manifest describes them. names must be unique file names, without path separators or "..".
+func Save(manifest string, s *Sharded) error {
+	dir := filepath.Dir(manifest)
+
+	names := make(map[string]bool, len(s.shards))
+	for _, sh := range s.shards {
+		if sh.Name == "" || sh.Name == "." || strings.Contains(sh.Name, "..") ||
+			strings.ContainsAny(sh.Name, `/\`) || names[sh.Name] {
+			return fmt.Errorf("%w: shard %q", ErrManifest, sh.Name)
+		}
+		names[sh.Name] = true
+	}
+
+	m := Manifest{Shards: make([]Entry, len(s.shards))}
+	for i, sh := range s.shards {
+		file := sh.Name + ".hfmi"
+		if err := ctor.WriteFile(filepath.Join(dir, file), sh.FMI); err != nil {
+			return err
+		}
+		m.Shards[i] = Entry{sh.Name, file, sh.FMI.Len(), sh.FMI.Documents()}
+	}
+
+	return WriteManifest(manifest, m)
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/rleiwang/hfmi"
+)
+
+// ErrShard shard id is out of range
+var ErrShard = errors.New("shard: shard out of range")
+
+// Shard independent index, e.g. one per day of logs
+type Shard struct {
+	Name string
+	FMI  hfmi.FMI
+}
+
+// Range range in BWT (S, E] of a shard
+type Range struct {
+	Shard int
+	S, E  uint
+}
+
+// Occurrence text offset of a shard
+type Occurrence struct {
+	Shard  int
+	Offset uint
+}
+
+// Sharded queries fan out to shards in parallel, results are merged in shard order
+type Sharded struct {
+	shards []Shard
+}
+
+// New returns sharded index of shards, shard id is the index in shards
+func New(shards ...Shard) *Sharded {
+	return &Sharded{shards: shards}
+}
+
+// Shards returns shards, shard id is the index
+func (s *Sharded) Shards() []Shard {
+	return s.shards
+}
+
+// Len returns the total length of shards
+func (s *Sharded) Len() uint {
+	n := uint(0)
+	for _, sh := range s.shards {
+		n += sh.FMI.Len()
+	}
+	return n
+}
+
+// Documents returns the total number of documents of shards
+func (s *Sharded) Documents() uint {
+	n := uint(0)
+	for _, sh := range s.shards {
+		n += sh.FMI.Documents()
+	}
+	return n
+}
+
+// Count returns the total number of pattern occurrences of shards
+func (s *Sharded) Count(p string) uint {
+	cnts := make([]uint, len(s.shards))
+	s.each(func(i int, fmi hfmi.FMI) {
+		cnts[i] = fmi.Count(p)
+	})
+
+	n := uint(0)
+	for _, c := range cnts {
+		n += c
+	}
+	return n
+}
+
+// Search returns ranges of shards where pattern is found, in shard order
+func (s *Sharded) Search(p string) []Range {
+	rngs := make([][]uint, len(s.shards))
+	s.each(func(i int, fmi hfmi.FMI) {
+		rngs[i], _ = fmi.Search(p)
+	})
+
+	var ret []Range
+	for i, r := range rngs {
+		if r != nil {
+			ret = append(ret, Range{i, r[0], r[1]})
+		}
+	}
+	return ret
+}
+
+// Occurrences returns text offsets of pattern occurrences, ordered by shard, then offset
+func (s *Sharded) Occurrences(p string) []Occurrence {
+	offs := make([][]uint, len(s.shards))
+	s.each(func(i int, fmi hfmi.FMI) {
+		offs[i] = fmi.Occurrences(p)
+	})
+
+	var ret []Occurrence
+	for i, o := range offs {
+		for _, off := range o {
+			ret = append(ret, Occurrence{i, off})
+		}
+	}
+	return ret
+}
+
+// SearchMany search patterns in batch, returns ranges keyed by found pattern, in shard order
+func (s *Sharded) SearchMany(patterns []string) map[string][]Range {
+	found := make([]map[string][]uint, len(s.shards))
+	s.each(func(i int, fmi hfmi.FMI) {
+		found[i] = fmi.SearchMany(patterns)
+	})
+
+	ret := make(map[string][]Range)
+	for i, f := range found {
+		for p, r := range f {
+			ret[p] = append(ret[p], Range{i, r[0], r[1]})
+		}
+	}
+	return ret
+}
+
+// ExtractRange extracts text of shard, see FMI.ExtractRange
+func (s *Sharded) ExtractRange(shard int, from, to uint) ([]byte, bool) {
+	if shard < 0 || shard >= len(s.shards) {
+		return nil, false
+	}
+	return s.shards[shard].FMI.ExtractRange(from, to)
+}
+
+// ExtractFields extracts fields of shard, see FMI.ExtractFields
+func (s *Sharded) ExtractFields(shard int, sep byte, p uint, fc uint) ([][]byte, bool) {
+	if shard < 0 || shard >= len(s.shards) {
+		return nil, false
+	}
+	return s.shards[shard].FMI.ExtractFields(sep, p, fc)
+}
+
+// ExtractRanges extracts text follows each occurrence of ranges up to byte to, byte 0 or 1, in parallel.
+// At most limit texts per range, negative for all, texts are in the order of ranges.
+func (s *Sharded) ExtractRanges(rngs []Range, to byte, limit int) [][][]byte {
+	ret := make([][][]byte, len(rngs))
+
+	var wg sync.WaitGroup
+	for i, r := range rngs {
+		if r.Shard < 0 || r.Shard >= len(s.shards) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, r Range) {
+			defer wg.Done()
+			fmi := s.shards[r.Shard].FMI
+			for p := r.S + 1; p <= r.E && (limit < 0 || len(ret[i]) < limit); p++ {
+				b, _, _ := fmi.ForwardExtractToChar(p, to)
+				ret[i] = append(ret[i], b)
+			}
+		}(i, r)
+	}
+	wg.Wait()
+
+	return ret
+}
+
+// RestoreDocument writes id-th document of shard to w
+func (s *Sharded) RestoreDocument(ctx context.Context, w io.Writer, shard int, id uint) error {
+	if shard < 0 || shard >= len(s.shards) {
+		return ErrShard
+	}
+	return s.shards[shard].FMI.RestoreDocument(ctx, w, id)
+}
+
+// Shard returns id of shard named name
+func (s *Sharded) Shard(name string) (int, error) {
+	for i, sh := range s.shards {
+		if sh.Name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrShard, name)
+}
+
+// each calls f of every shard in parallel
+func (s *Sharded) each(f func(i int, fmi hfmi.FMI)) {
+	var wg sync.WaitGroup
+	wg.Add(len(s.shards))
+	for i, sh := range s.shards {
+		go func(i int, fmi hfmi.FMI) {
+			defer wg.Done()
+			f(i, fmi)
+		}(i, sh.FMI)
+	}
+	wg.Wait()
+}
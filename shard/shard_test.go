@@ -0,0 +1,164 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package shard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rleiwang/hfmi/ctor"
+	"github.com/rleiwang/hfmi/internal/testutil"
+)
+
+func days() *Sharded {
+	return New(
+		Shard{"day1", ctor.New([]byte("login ok\x01login failed"))},
+		Shard{"day2", ctor.New([]byte("logout\x01disk full"))},
+		Shard{"day3", ctor.New([]byte("login failed\x01login failed again"))},
+	)
+}
+
+func TestSharded(t *testing.T) {
+	s := days()
+
+	tests := []struct {
+		name    string
+		pattern string
+		count   uint
+		occs    []Occurrence
+	}{
+		{"all", "log", 5, []Occurrence{{0, 0}, {0, 9}, {1, 0}, {2, 0}, {2, 13}}},
+		{"some", "failed", 3, []Occurrence{{0, 15}, {2, 6}, {2, 19}}},
+		{"one", "disk", 1, []Occurrence{{1, 7}}},
+		{"none", "reboot", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Count(tt.pattern); got != tt.count {
+				t.Errorf("Count() = %v, want %v", got, tt.count)
+			}
+			if got := s.Occurrences(tt.pattern); !reflect.DeepEqual(got, tt.occs) {
+				t.Errorf("Occurrences() = %v, want %v", got, tt.occs)
+			}
+
+			cnt := uint(0)
+			for _, r := range s.Search(tt.pattern) {
+				cnt += r.E - r.S
+			}
+			if cnt != tt.count {
+				t.Errorf("Search() = %v occurrences, want %v", cnt, tt.count)
+			}
+			if got := s.SearchMany([]string{tt.pattern})[tt.pattern]; !reflect.DeepEqual(got, s.Search(tt.pattern)) {
+				t.Errorf("SearchMany() = %v, want %v", got, s.Search(tt.pattern))
+			}
+		})
+	}
+
+	texts := s.ExtractRanges(s.Search("login f"), 1, -1)
+	if want := [][][]byte{{[]byte("ailed")}, {[]byte("ailed"), []byte("ailed again")}}; !reflect.DeepEqual(texts, want) && !reflect.DeepEqual(texts, [][][]byte{want[0], {want[1][1], want[1][0]}}) {
+		t.Errorf("ExtractRanges() = %q, want %q", texts, want)
+	}
+
+	var buf bytes.Buffer
+	if err := s.RestoreDocument(context.Background(), &buf, 1, 1); err != nil || buf.String() != "disk full" {
+		t.Errorf("RestoreDocument() = %q, %v", buf.String(), err)
+	}
+	if err := s.RestoreDocument(context.Background(), &buf, 3, 0); err != ErrShard {
+		t.Errorf("RestoreDocument() error = %v, want %v", err, ErrShard)
+	}
+}
+
+// TestShardedConcurrent shards share an index of sparse blocks, queried by concurrent goroutines, see -race
+func TestShardedConcurrent(t *testing.T) {
+	text := testutil.Skewed(3, 1<<15)
+	fmi := ctor.New(append([]byte{}, text...))
+	s := New(Shard{"a", fmi}, Shard{"b", fmi}, Shard{"c", fmi}, Shard{"d", fmi})
+
+	var want []uint
+	for i := 0; i+2 <= len(text); i++ {
+		if bytes.Equal(text[i:i+2], []byte("ab")) {
+			want = append(want, uint(i))
+		}
+	}
+	occs := s.Occurrences("ab")
+	if len(occs) != len(s.Shards())*len(want) {
+		t.Fatalf("Occurrences() = %v occurrences, want %v", len(occs), len(s.Shards())*len(want))
+	}
+	for i, o := range occs {
+		if o.Shard != i/len(want) || o.Offset != want[i%len(want)] {
+			t.Fatalf("Occurrences()[%v] = %v, want %v", i, o, Occurrence{i / len(want), want[i%len(want)]})
+		}
+	}
+
+	rngs := s.Search("b")
+	var texts [][]byte
+	for p := rngs[0].S + 1; p <= rngs[0].E; p++ {
+		b, _, _ := fmi.ForwardExtractToChar(p, 1)
+		texts = append(texts, b)
+	}
+	for i, got := range s.ExtractRanges(rngs, 1, -1) {
+		if !reflect.DeepEqual(got, texts) {
+			t.Fatalf("ExtractRanges() of shard %v differs", i)
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "logs.json")
+	if err := Save(manifest, days()); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := s.Shard("day2"); err != nil || i != 1 || s.Count("login") != 4 || s.Documents() != 6 {
+		t.Errorf("Open() = shard %v, %v, count %v, documents %v", i, err, s.Count("login"), s.Documents())
+	}
+
+	// nothing is written on invalid names
+	sub := filepath.Join(dir, "sub")
+	os.Mkdir(sub, 0755)
+	for _, name := range []string{"", "..", "../day1", "a/b", `a\b`, "day1"} {
+		s := New(Shard{"day1", ctor.New([]byte("login ok"))}, Shard{name, ctor.New([]byte("logout"))})
+		if err := Save(filepath.Join(sub, "bad.json"), s); !errors.Is(err, ErrManifest) {
+			t.Errorf("Save(%q) error = %v, want %v", name, err, ErrManifest)
+		}
+	}
+	if files, _ := filepath.Glob(filepath.Join(sub, "*")); len(files) != 0 {
+		t.Errorf("Save() wrote %v", files)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	for _, m := range []string{`{"version":2}`, `{"version":1,"shards":[{"name":"a","file":"a"},{"name":"a","file":"b"}]}`, `[]`} {
+		ioutil.WriteFile(bad, []byte(m), 0644)
+		if _, err := Open(bad); !errors.Is(err, ErrManifest) {
+			t.Errorf("Open(%s) error = %v, want %v", m, err, ErrManifest)
+		}
+	}
+}
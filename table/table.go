@@ -0,0 +1,315 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package table
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/rleiwang/hfmi"
+)
+
+// Type type of column value
+type Type byte
+
+const (
+	Bytes Type = iota
+	String
+	Int
+	Float
+	Bool
+)
+
+var (
+	// ErrSeparator fields must be separated by byte 1, the only separator kept in text order
+	ErrSeparator = errors.New("table: separator must be byte 1")
+	// ErrSchema schema has no column
+	ErrSchema = errors.New("table: schema has no column")
+	// ErrRagged record doesn't have the declared number of fields
+	ErrRagged = errors.New("table: ragged record")
+	// ErrRow row is out of range
+	ErrRow = errors.New("table: row out of range")
+	// ErrColumn column is not declared in schema
+	ErrColumn = errors.New("table: unknown column")
+)
+
+// Column declares name and type of a column
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Schema declares columns of records, fields are separated by Sep,
+// the last field of each record ends with Terminator, if it is not zero.
+type Schema struct {
+	Columns    []Column
+	Sep        byte
+	Terminator byte
+}
+
+// Table records in FM-index
+type Table struct {
+	fmi    hfmi.FMI
+	schema Schema
+	rows   uint
+}
+
+// New returns table of fmi, with declared schema
+func New(fmi hfmi.FMI, schema Schema) (*Table, error) {
+	if schema.Sep == 0 {
+		schema.Sep = 1
+	}
+	if schema.Sep != 1 {
+		return nil, ErrSeparator
+	}
+	if len(schema.Columns) == 0 {
+		return nil, ErrSchema
+	}
+
+	// number of fields = number of separators + 1
+	fc, fields := uint(len(schema.Columns)), uint(1)
+	if s, e, ok := fmi.GetBound(schema.Sep); ok {
+		fields += e - s
+	}
+	if fields%fc != 0 {
+		return nil, fmt.Errorf("%w: %d fields is not multiple of %d columns", ErrRagged, fields, fc)
+	}
+
+	return &Table{fmi: fmi, schema: schema, rows: fields / fc}, nil
+}
+
+// Schema returns schema of the table
+func (t *Table) Schema() Schema {
+	return t.schema
+}
+
+// Record returns fields of i-th row keyed by column name
+func (t *Table) Record(i uint) (map[string][]byte, error) {
+	fields, err := t.fields(i)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := make(map[string][]byte, len(fields))
+	for j, c := range t.schema.Columns {
+		rec[c.Name] = fields[j]
+	}
+	return rec, nil
+}
+
+// Values returns typed values of i-th row keyed by column name
+func (t *Table) Values(i uint) (map[string]interface{}, error) {
+	fields, err := t.fields(i)
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make(map[string]interface{}, len(fields))
+	for j, c := range t.schema.Columns {
+		if vals[c.Name], err = parse(c.Type, fields[j]); err != nil {
+			return nil, fmt.Errorf("table: row %d column %s: %w", i, c.Name, err)
+		}
+	}
+	return vals, nil
+}
+
+// SearchInColumn returns positions in BWT of pattern occurrences inside field of column col
+func (t *Table) SearchInColumn(col, pattern string) ([]uint, error) {
+	c, err := t.column(col)
+	if err != nil {
+		return nil, err
+	}
+
+	rng, ok := t.fmi.Search(pattern)
+	if !ok || bytes.IndexByte([]byte(pattern), t.schema.Sep) >= 0 {
+		return nil, nil
+	}
+
+	fc := uint(len(t.schema.Columns))
+
+	var ret []uint
+	for p := rng[0] + 1; p <= rng[1]; p++ {
+		f, err := t.fieldOf(p)
+		if err != nil {
+			return nil, err
+		}
+		if f%fc == c {
+			ret = append(ret, p)
+		}
+	}
+
+	return ret, nil
+}
+
+// Lookup returns records whose field of column col equals to value, in row order
+func (t *Table) Lookup(col, value string) ([]map[string][]byte, error) {
+	c, err := t.column(col)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.lookup(c, []byte(value))
+	if err != nil {
+		return nil, err
+	}
+
+	recs := make([]map[string][]byte, len(rows))
+	for i, r := range rows {
+		if recs[i], err = t.Record(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return recs, nil
+}
+
+// lookup returns rows whose field of column c equals to value, in row order
+func (t *Table) lookup(c uint, value []byte) ([]uint, error) {
+	sep, term := t.schema.Sep, t.schema.Terminator
+	if bytes.IndexByte(value, sep) >= 0 || (term != 0 && bytes.IndexByte(value, term) >= 0) {
+		return nil, nil
+	}
+
+	fc := uint(len(t.schema.Columns))
+	if term != 0 && c == fc-1 {
+		value = append(value, term)
+	}
+
+	var rows []uint
+	if c == 0 {
+		// the first field of the text is not preceded by separator
+		if fields, err := t.fields(0); err == nil && bytes.Equal(fields[0], value) {
+			rows = append(rows, 0)
+		}
+	}
+
+	// field bounded by separators, sep + value + (sep | end of text)
+	rng, ok := t.fmi.Search(string(append([]byte{sep}, value...)))
+	if !ok {
+		return rows, nil
+	}
+
+	for p := rng[0] + 1; p <= rng[1]; p++ {
+		// L[p] is the char follows the occurrence
+		if b, _, _ := t.fmi.Access(p); b != sep && b != 0 {
+			continue
+		}
+		f, err := t.fieldOf(p)
+		if err != nil {
+			return nil, err
+		}
+		if f%fc == c {
+			rows = append(rows, f/fc)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i] < rows[j] })
+
+	return rows, nil
+}
+
+// RowCount returns the number of rows
+func (t *Table) RowCount() uint {
+	return t.rows
+}
+
+// Row returns fields of i-th row in column order
+func (t *Table) Row(i uint) ([][]byte, error) {
+	return t.fields(i)
+}
+
+// RowOf returns the row where position p in BWT falls in, e.g. a position in range of Search
+func (t *Table) RowOf(p uint) (uint, error) {
+	f, err := t.fieldOf(p)
+	if err != nil {
+		return 0, err
+	}
+	return f / uint(len(t.schema.Columns)), nil
+}
+
+// fieldOf returns zero based field number where position p in BWT falls in
+func (t *Table) fieldOf(p uint) (uint, error) {
+	if p >= t.fmi.Len() {
+		return 0, fmt.Errorf("table: position %d out of range", p)
+	}
+
+	// walk to the preceding separator, the separator rank is the field number
+	q, ok := t.fmi.BackwardJumpToChar(p, t.schema.Sep)
+	if !ok {
+		return 0, fmt.Errorf("table: position %d: failed to locate field", p)
+	}
+	offset, _, _ := t.fmi.GetBound(t.schema.Sep)
+	return q - offset, nil
+}
+
+// column returns index of column col
+func (t *Table) column(col string) (uint, error) {
+	for i, c := range t.schema.Columns {
+		if c.Name == col {
+			return uint(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrColumn, col)
+}
+
+// fields returns fields of i-th row, the terminator is removed from the last field
+func (t *Table) fields(i uint) ([][]byte, error) {
+	if i >= t.rows {
+		return nil, ErrRow
+	}
+
+	fc := uint(len(t.schema.Columns))
+	fields, ok := t.fmi.ExtractFields(t.schema.Sep, i*fc, fc)
+	if !ok {
+		return nil, fmt.Errorf("%w: row %d", ErrRagged, i)
+	}
+
+	if term := t.schema.Terminator; term != 0 {
+		// only the last field ends with terminator
+		for j, f := range fields[:fc-1] {
+			if bytes.IndexByte(f, term) >= 0 {
+				return nil, fmt.Errorf("%w: row %d ends at column %s", ErrRagged, i, t.schema.Columns[j].Name)
+			}
+		}
+		last := fields[fc-1]
+		if len(last) == 0 || bytes.IndexByte(last, term) != len(last)-1 {
+			return nil, fmt.Errorf("%w: row %d doesn't end at column %s", ErrRagged, i, t.schema.Columns[fc-1].Name)
+		}
+		fields[fc-1] = last[:len(last)-1]
+	}
+
+	return fields, nil
+}
+
+func parse(t Type, v []byte) (interface{}, error) {
+	switch t {
+	case String:
+		return string(v), nil
+	case Int:
+		return strconv.ParseInt(string(v), 10, 64)
+	case Float:
+		return strconv.ParseFloat(string(v), 64)
+	case Bool:
+		return strconv.ParseBool(string(v))
+	}
+	return v, nil
+}
@@ -0,0 +1,268 @@
+/*
+ * Copyright 2020 Rock Lei Wang
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Package parser declares an expression parser with support for macro
+ * expansion.
+ */
+
+package table
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rleiwang/hfmi/ctor"
+)
+
+var people = Schema{
+	Columns:    []Column{{"id", Int}, {"name", String}, {"score", Float}, {"active", Bool}},
+	Terminator: '\n',
+}
+
+func TestRecord(t *testing.T) {
+	text := strings.Join([]string{"1", "alice", "3.5", "true\n", "2", "bob", "4", "false\n", "3", "carol", "0.5", "true\n"}, "\x01")
+	tbl, err := New(ctor.New([]byte(text)), people)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		row  uint
+		want map[string]interface{}
+		err  error
+	}{
+		{"first", 0, map[string]interface{}{"id": int64(1), "name": "alice", "score": 3.5, "active": true}, nil},
+		{"middle", 1, map[string]interface{}{"id": int64(2), "name": "bob", "score": 4.0, "active": false}, nil},
+		{"last", 2, map[string]interface{}{"id": int64(3), "name": "carol", "score": 0.5, "active": true}, nil},
+		{"out", 3, nil, ErrRow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tbl.Values(tt.row)
+			if !errors.Is(err, tt.err) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Values(%v) = %v, %v, want %v, %v", tt.row, got, err, tt.want, tt.err)
+			}
+		})
+	}
+
+	if rec, err := tbl.Record(1); err != nil || string(rec["name"]) != "bob" || string(rec["active"]) != "false" {
+		t.Errorf("Record(1) = %q, %v", rec, err)
+	}
+}
+
+func TestRagged(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		row    uint
+		err    error
+	}{
+		{"count", []string{"1", "alice", "3.5", "true\n", "2", "bob", "false\n"}, 0, ErrRagged},
+		{"short", []string{"1", "alice", "true\n", "2", "bob", "4", "false", "x\n"}, 0, ErrRagged},
+		{"long", []string{"1", "alice", "3.5", "true", "x\n", "2", "bob", "false\n"}, 0, ErrRagged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tbl, err := New(ctor.New([]byte(strings.Join(tt.fields, "\x01"))), people)
+			if err == nil {
+				_, err = tbl.Values(tt.row)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Values(%v) error = %v, want %v", tt.row, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEmptyLastField(t *testing.T) {
+	// New rejects trailing separators, index of "a\x01" is restored from bytes instead
+	d := []byte("\x10\x00\x00\x00\x00\x00\x00\x00\x03\x00\x00\x00\x00\x00\x00\x00\xc3\x04\x00\x01\x01\x01\x02\x01\x02\x00\x01\x02")
+	if err := ctor.Validate(3, []byte("\x00\x01a"), d); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	schema := Schema{Columns: []Column{{"key", String}, {"value", String}}, Terminator: '\n'}
+	tbl, err := New(ctor.Build(3, []byte("\x00\x01a"), d), schema)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if rec, err := tbl.Record(0); !errors.Is(err, ErrRagged) {
+		t.Errorf("Record(0) = %q, %v, want %v", rec, err, ErrRagged)
+	}
+}
+
+func TestValuesType(t *testing.T) {
+	tbl, err := New(ctor.New([]byte(strings.Join([]string{"x", "alice", "3.5", "true\n"}, "\x01"))), people)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := tbl.Values(0); err == nil || errors.Is(err, ErrRagged) {
+		t.Errorf("Values(0) error = %v, want parse error", err)
+	}
+}
+
+func TestSearchInColumn(t *testing.T) {
+	text := strings.Join([]string{"1", "alice", "bob", "true\n", "2", "bob", "alice", "false\n", "3", "bobby", "carol", "true\n"}, "\x01")
+	schema := Schema{
+		Columns:    []Column{{"id", Int}, {"name", String}, {"friend", String}, {"active", Bool}},
+		Terminator: '\n',
+	}
+	tbl, err := New(ctor.New([]byte(text)), schema)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		col     string
+		pattern string
+		want    []uint // rows
+		err     error
+	}{
+		{"name", "name", "bob", []uint{1, 2}, nil},
+		{"friend", "friend", "bob", []uint{0}, nil},
+		{"id", "id", "bob", nil, nil},
+		{"first", "id", "2", []uint{1}, nil},
+		{"last", "active", "true", []uint{0, 2}, nil},
+		{"unknown", "age", "bob", nil, ErrColumn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tbl.SearchInColumn(tt.col, tt.pattern)
+			if !errors.Is(err, tt.err) || len(got) != len(tt.want) {
+				t.Fatalf("SearchInColumn(%q, %q) = %v, %v, want %v rows, %v", tt.col, tt.pattern, got, err, len(tt.want), tt.err)
+			}
+			rows := map[uint]bool{}
+			for _, p := range got {
+				fields, ok := tbl.fmi.ExtractFields(1, p, 4)
+				if !ok {
+					t.Fatalf("ExtractFields(%v) failed", p)
+				}
+				// id is row + 1
+				rows[uint(fields[0][0]-'1')] = true
+			}
+			for _, r := range tt.want {
+				if !rows[r] {
+					t.Errorf("SearchInColumn(%q, %q) = %v, missing row %v", tt.col, tt.pattern, got, r)
+				}
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	text := strings.Join([]string{"ACME", "acme inc", "ACME\n", "ACMEX", "ACME", "NYSE\n", "NASDAQ", "ACME corp", "ACME\n"}, "\x01")
+	schema := Schema{
+		Columns:    []Column{{"ticker", String}, {"name", String}, {"exchange", String}},
+		Terminator: '\n',
+	}
+	tbl, err := New(ctor.New([]byte(text)), schema)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	noterm, err := New(ctor.New([]byte(strings.ReplaceAll(text, "\n", ""))), Schema{Columns: schema.Columns})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		col   string
+		value string
+		want  []string // tickers
+	}{
+		{"first", "ticker", "ACME", []string{"ACME"}},
+		{"middle", "name", "ACME", []string{"ACMEX"}},
+		{"last", "exchange", "ACME", []string{"ACME", "NASDAQ"}},
+		{"prefix", "name", "ACME corp", []string{"NASDAQ"}},
+		{"none", "name", "ACM", nil},
+	}
+	for _, tt := range tests {
+		for _, tbl := range []*Table{tbl, noterm} {
+			t.Run(tt.name, func(t *testing.T) {
+				recs, err := tbl.Lookup(tt.col, tt.value)
+				var got []string
+				for _, r := range recs {
+					got = append(got, string(r["ticker"]))
+					if string(r[tt.col]) != tt.value {
+						t.Errorf("Lookup(%q, %q) = %q", tt.col, tt.value, r)
+					}
+				}
+				if err != nil || !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Lookup(%q, %q) = %v, %v, want %v", tt.col, tt.value, got, err, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestRowOf(t *testing.T) {
+	fields := []string{"1", "alice", "3.5", "true\n", "2", "bob", "4", "false\n", "3", "carol", "0.5", "true\n"}
+	fmi := ctor.New([]byte(strings.Join(fields, "\x01")))
+	tbl, err := New(fmi, people)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if tbl.RowCount() != 3 {
+		t.Errorf("RowCount() = %v, want 3", tbl.RowCount())
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []uint
+	}{
+		{"first", "1", []uint{0}},
+		{"name", "bob", []uint{1}},
+		{"last", "true", []uint{0, 2}},
+		{"many", "a", []uint{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng, _ := fmi.Search(tt.pattern)
+			var got []uint
+			for p := rng[0] + 1; p <= rng[1]; p++ {
+				r, err := tbl.RowOf(p)
+				if err != nil {
+					t.Fatalf("RowOf(%v) error = %v", p, err)
+				}
+				row, err := tbl.Row(r)
+				if err != nil || !strings.Contains(strings.Join(asStrings(row), "\x01"), tt.pattern) {
+					t.Errorf("Row(%v) = %q, %v, want containing %q", r, row, err, tt.pattern)
+				}
+				got = append(got, r)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RowOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := tbl.Row(3); !errors.Is(err, ErrRow) {
+		t.Errorf("Row(3) error = %v, want %v", err, ErrRow)
+	}
+}
+
+func asStrings(fields [][]byte) []string {
+	ret := make([]string, len(fields))
+	for i, f := range fields {
+		ret[i] = string(f)
+	}
+	return ret
+}